@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultMaxEntries is the entry limit used when none is configured.
+const DefaultMaxEntries = 1000
+
+// entry is a cached value with the time it was stored, its expiry time and the
+// time it is dropped, which is later than the expiry while it may be served stale.
+type entry struct {
+	key       string
+	value     interface{}
+	storedAt  time.Time
+	expiresAt time.Time
+	removeAt  time.Time
+}
+
+// Cache is a concurrency-safe in-memory cache with per-entry TTLs, holding at
+// most maxEntries entries; beyond that the least recently used are evicted.
+type Cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	// order lists the entries from most to least recently used
+	order *list.List
+}
+
+// New creates an empty cache holding at most maxEntries entries, or
+// DefaultMaxEntries when maxEntries is not positive.
+func New(maxEntries int) *Cache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &Cache{maxEntries: maxEntries, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// Get returns the cached value for key if it has not expired. Expired entries
+// are kept for the staleFor they were stored with so GetStale can still serve
+// them, and dropped after that.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.lookup(key)
+	if !ok || time.Now().After(e.expiresAt) {
+		return nil, false
+	}
+	return e.value, true
+}
+
+// GetStale returns the value for key even if it has expired, as long as it was
+// stored no more than maxAge ago and is still retained.
+func (c *Cache) GetStale(key string, maxAge time.Duration) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.lookup(key)
+	if !ok || time.Since(e.storedAt) > maxAge {
+		return nil, false
+	}
+	return e.value, true
+}
+
+// Set stores value under key for the given TTL, retaining it for staleFor
+// after it expires. When the cache is full, entries past their retention are
+// dropped first, then the least recently used.
+func (c *Cache) Set(key string, value interface{}, ttl, staleFor time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	e := &entry{key: key, value: value, storedAt: now, expiresAt: now.Add(ttl), removeAt: now.Add(ttl + staleFor)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = e
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(e)
+
+	if len(c.entries) > c.maxEntries {
+		c.dropExpired(now)
+	}
+	for len(c.entries) > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+// Len returns the number of entries held, including expired ones still retained.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// Delete removes the given keys and returns how many were present.
+func (c *Cache) Delete(keys ...string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deleted := 0
+	for _, key := range keys {
+		if element, ok := c.entries[key]; ok {
+			c.remove(element)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Clear removes every entry and returns how many there were.
+func (c *Cache) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cleared := len(c.entries)
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	return cleared
+}
+
+// lookup returns the entry for key, marking it as recently used. An entry past
+// its retention is dropped and reported missing.
+func (c *Cache) lookup(key string) (*entry, bool) {
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := element.Value.(*entry)
+	if time.Now().After(e.removeAt) {
+		c.remove(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return e, true
+}
+
+// dropExpired removes every entry past its retention.
+func (c *Cache) dropExpired(now time.Time) {
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		if now.After(element.Value.(*entry).removeAt) {
+			c.remove(element)
+		}
+		element = next
+	}
+}
+
+// remove drops an entry.
+func (c *Cache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*entry).key)
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetDropsExpiredEntriesWithoutStaleRetention(t *testing.T) {
+	c := New(10)
+	c.Set("a", 1, -time.Second, 0)
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get returned an expired entry")
+	}
+	if c.Len() != 0 {
+		t.Fatalf("Len = %d after reading an expired entry, want 0", c.Len())
+	}
+}
+
+func TestGetStaleServesRetainedEntries(t *testing.T) {
+	c := New(10)
+	c.Set("a", 1, -time.Second, time.Minute)
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get returned an expired entry")
+	}
+	value, ok := c.GetStale("a", time.Minute)
+	if !ok || value != 1 {
+		t.Fatalf("GetStale = %v, %v; want 1, true", value, ok)
+	}
+	if _, ok := c.GetStale("a", -time.Second); ok {
+		t.Fatal("GetStale returned an entry older than maxAge")
+	}
+}
+
+func TestSetEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New(2)
+	c.Set("a", 1, time.Minute, 0)
+	c.Set("b", 2, time.Minute, 0)
+	c.Get("a")
+	c.Set("c", 3, time.Minute, 0)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry b was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("entry %s was evicted", key)
+		}
+	}
+}
+
+func TestSetDropsExpiredBeforeEvicting(t *testing.T) {
+	c := New(2)
+	c.Set("a", 1, time.Minute, 0)
+	c.Set("old", 2, -time.Second, 0)
+	c.Set("b", 3, time.Minute, 0)
+
+	if c.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", c.Len())
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("live entry a was evicted instead of the expired one")
+	}
+}
+
+func TestDeleteAndClear(t *testing.T) {
+	c := New(10)
+	c.Set("a", 1, time.Minute, 0)
+	c.Set("b", 2, time.Minute, 0)
+
+	if n := c.Delete("a", "missing"); n != 1 {
+		t.Errorf("Delete = %d, want 1", n)
+	}
+	if n := c.Clear(); n != 1 {
+		t.Errorf("Clear = %d, want 1", n)
+	}
+	if c.Len() != 0 {
+		t.Errorf("Len = %d after Clear, want 0", c.Len())
+	}
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration read once at startup. Settings that can change
+// without a restart live in Runtime, which Config carries as loaded at startup
+// and requests read through config.FromContext. Each field's env tag names the
+// variable it is read from.
+type Config struct {
+	// Port is the HTTP port the server listens on (PORT, default 4000).
+	Port string `env:"PORT"`
+	// ProjectID and DatabaseID name the Firestore database (required).
+	ProjectID  string `env:"PROJECT_ID"`
+	DatabaseID string `env:"DATABASE_ID"`
+	// Backend selects the Firestore backend (FIRESTORE_BACKEND, default rest).
+	Backend string `env:"FIRESTORE_BACKEND"`
+	// AppEnv is the deployment environment (APP_ENV).
+	AppEnv string `env:"APP_ENV"`
+	// RoutesConfigFile is the file defining config-driven routes (ROUTES_CONFIG_FILE).
+	RoutesConfigFile string `env:"ROUTES_CONFIG_FILE"`
+	// RequestTimeout bounds requests to routes without a RouteTimeouts entry
+	// (REQUEST_TIMEOUT); zero leaves them unbounded.
+	RequestTimeout time.Duration `env:"REQUEST_TIMEOUT"`
+	// RouteTimeouts bounds requests by registered route pattern (ROUTE_TIMEOUTS).
+	RouteTimeouts map[string]time.Duration `env:"ROUTE_TIMEOUTS"`
+	// CacheMaxEntries bounds the number of cached collection reads
+	// (CACHE_MAX_ENTRIES, default cache.DefaultMaxEntries).
+	CacheMaxEntries int `env:"CACHE_MAX_ENTRIES"`
+	// WarmCollections are fetched into the cache at startup (WARM_COLLECTIONS).
+	WarmCollections []string `env:"WARM_COLLECTIONS"`
+	// EmulatorHost points Firestore calls at a local emulator (FIRESTORE_EMULATOR_HOST).
+	EmulatorHost string `env:"FIRESTORE_EMULATOR_HOST"`
+	// MaxConcurrency bounds concurrent Firestore calls across all requests
+	// (FIRESTORE_MAX_CONCURRENCY); zero leaves them unbounded.
+	MaxConcurrency int `env:"FIRESTORE_MAX_CONCURRENCY"`
+	// ProxyURL routes Firestore calls through a proxy (FIRESTORE_PROXY_URL);
+	// nil uses HTTP_PROXY/HTTPS_PROXY/NO_PROXY.
+	ProxyURL *url.URL `env:"FIRESTORE_PROXY_URL"`
+	// IdleConnTimeout and KeepAlive tune pooled Firestore connections
+	// (FIRESTORE_IDLE_CONN_TIMEOUT, FIRESTORE_KEEPALIVE); zero keeps Go's defaults.
+	IdleConnTimeout time.Duration `env:"FIRESTORE_IDLE_CONN_TIMEOUT"`
+	KeepAlive       time.Duration `env:"FIRESTORE_KEEPALIVE"`
+	// LogSampleRate logs 1 in every N successful requests (LOG_SAMPLE_RATE, default 1).
+	LogSampleRate uint64 `env:"LOG_SAMPLE_RATE"`
+	// LogSlowThreshold is the latency above which requests are always logged
+	// (LOG_SLOW_THRESHOLD, default 1s).
+	LogSlowThreshold time.Duration `env:"LOG_SLOW_THRESHOLD"`
+	// Runtime is the reloadable configuration, including the cache TTL.
+	Runtime *Runtime
+}
+
+// LoadConfig builds a Config from env (see LoadEnv), applying defaults and
+// failing on missing or invalid values.
+func LoadConfig(env map[string]string) (Config, error) {
+	cfg := Config{
+		Port:             env["PORT"],
+		ProjectID:        env["PROJECT_ID"],
+		DatabaseID:       env["DATABASE_ID"],
+		Backend:          env["FIRESTORE_BACKEND"],
+		AppEnv:           env["APP_ENV"],
+		RoutesConfigFile: env["ROUTES_CONFIG_FILE"],
+		RouteTimeouts:    make(map[string]time.Duration),
+		EmulatorHost:     env["FIRESTORE_EMULATOR_HOST"],
+		LogSampleRate:    1,
+		LogSlowThreshold: time.Second,
+	}
+	if cfg.ProjectID == "" || cfg.DatabaseID == "" {
+		return Config{}, fmt.Errorf("environment variables PROJECT_ID and DATABASE_ID must be set")
+	}
+	if cfg.Port == "" {
+		cfg.Port = "4000"
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = "rest"
+	}
+
+	if raw := env["REQUEST_TIMEOUT"]; raw != "" {
+		value, err := time.ParseDuration(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid REQUEST_TIMEOUT %q: %v", raw, err)
+		}
+		if value <= 0 {
+			return Config{}, fmt.Errorf("invalid REQUEST_TIMEOUT %q: must be positive", raw)
+		}
+		cfg.RequestTimeout = value
+	}
+
+	for _, pair := range strings.Split(env["ROUTE_TIMEOUTS"], ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		route, raw, _ := strings.Cut(pair, "=")
+		value, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid ROUTE_TIMEOUTS entry %q: %v", pair, err)
+		}
+		if value <= 0 {
+			return Config{}, fmt.Errorf("invalid ROUTE_TIMEOUTS entry %q: must be positive", pair)
+		}
+		cfg.RouteTimeouts[strings.TrimSpace(route)] = value
+	}
+
+	if raw := env["CACHE_MAX_ENTRIES"]; raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value <= 0 {
+			return Config{}, fmt.Errorf("invalid CACHE_MAX_ENTRIES %q: must be a positive integer", raw)
+		}
+		cfg.CacheMaxEntries = value
+	}
+
+	for _, name := range strings.Split(env["WARM_COLLECTIONS"], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.WarmCollections = append(cfg.WarmCollections, name)
+		}
+	}
+
+	if raw := env["FIRESTORE_MAX_CONCURRENCY"]; raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value <= 0 {
+			return Config{}, fmt.Errorf("invalid FIRESTORE_MAX_CONCURRENCY %q: must be a positive integer", raw)
+		}
+		cfg.MaxConcurrency = value
+	}
+
+	if raw := env["FIRESTORE_PROXY_URL"]; raw != "" {
+		proxyURL, err := url.Parse(raw)
+		if err != nil || proxyURL.Host == "" {
+			// The value is left out since it may hold proxy credentials
+			return Config{}, fmt.Errorf("invalid FIRESTORE_PROXY_URL: must be an absolute URL such as http://proxy:3128")
+		}
+		cfg.ProxyURL = proxyURL
+	}
+
+	durations := []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"FIRESTORE_IDLE_CONN_TIMEOUT", &cfg.IdleConnTimeout},
+		{"FIRESTORE_KEEPALIVE", &cfg.KeepAlive},
+		{"LOG_SLOW_THRESHOLD", &cfg.LogSlowThreshold},
+	}
+	for _, d := range durations {
+		if raw := env[d.name]; raw != "" {
+			value, err := time.ParseDuration(raw)
+			if err != nil || value <= 0 {
+				return Config{}, fmt.Errorf("invalid %s %q: must be a positive duration", d.name, raw)
+			}
+			*d.value = value
+		}
+	}
+
+	if raw := env["LOG_SAMPLE_RATE"]; raw != "" {
+		value, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || value == 0 {
+			return Config{}, fmt.Errorf("invalid LOG_SAMPLE_RATE %q: must be a positive integer", raw)
+		}
+		cfg.LogSampleRate = value
+	}
+
+	rt, err := LoadRuntime(env)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Runtime = rt
+
+	return cfg, nil
+}
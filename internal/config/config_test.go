@@ -0,0 +1,87 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, err := LoadConfig(map[string]string{"PROJECT_ID": "project", "DATABASE_ID": "(default)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "4000" || cfg.Backend != "rest" {
+		t.Errorf("Port, Backend = %q, %q; want 4000, rest", cfg.Port, cfg.Backend)
+	}
+	if cfg.RequestTimeout != 0 || cfg.MaxConcurrency != 0 || cfg.ProxyURL != nil {
+		t.Errorf("RequestTimeout, MaxConcurrency, ProxyURL = %v, %d, %v; want unset", cfg.RequestTimeout, cfg.MaxConcurrency, cfg.ProxyURL)
+	}
+	if cfg.LogSampleRate != 1 || cfg.LogSlowThreshold != time.Second {
+		t.Errorf("LogSampleRate, LogSlowThreshold = %d, %v; want 1, 1s", cfg.LogSampleRate, cfg.LogSlowThreshold)
+	}
+	if cfg.Runtime == nil || cfg.Runtime.CacheTTL != 0 {
+		t.Errorf("Runtime = %+v, want a Runtime without a cache TTL", cfg.Runtime)
+	}
+}
+
+func TestLoadConfigOverrides(t *testing.T) {
+	cfg, err := LoadConfig(map[string]string{
+		"PROJECT_ID":                "project",
+		"DATABASE_ID":               "(default)",
+		"PORT":                      "8080",
+		"REQUEST_TIMEOUT":           "10s",
+		"ROUTE_TIMEOUTS":            "/documents/*path=30s, /search=2s",
+		"CACHE_TTL":                 "1m",
+		"CACHE_MAX_ENTRIES":         "50",
+		"WARM_COLLECTIONS":          "restaurants, orders",
+		"FIRESTORE_MAX_CONCURRENCY": "20",
+		"FIRESTORE_PROXY_URL":       "http://proxy:3128",
+		"FIRESTORE_KEEPALIVE":       "15s",
+		"LOG_SAMPLE_RATE":           "10",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "8080" || cfg.RequestTimeout != 10*time.Second || cfg.CacheMaxEntries != 50 {
+		t.Errorf("Port, RequestTimeout, CacheMaxEntries = %q, %v, %d", cfg.Port, cfg.RequestTimeout, cfg.CacheMaxEntries)
+	}
+	if cfg.RouteTimeouts["/documents/*path"] != 30*time.Second || cfg.RouteTimeouts["/search"] != 2*time.Second {
+		t.Errorf("RouteTimeouts = %v", cfg.RouteTimeouts)
+	}
+	if len(cfg.WarmCollections) != 2 || cfg.WarmCollections[1] != "orders" {
+		t.Errorf("WarmCollections = %q", cfg.WarmCollections)
+	}
+	if cfg.MaxConcurrency != 20 || cfg.ProxyURL == nil || cfg.ProxyURL.Host != "proxy:3128" {
+		t.Errorf("MaxConcurrency, ProxyURL = %d, %v", cfg.MaxConcurrency, cfg.ProxyURL)
+	}
+	if cfg.KeepAlive != 15*time.Second || cfg.LogSampleRate != 10 {
+		t.Errorf("KeepAlive, LogSampleRate = %v, %d", cfg.KeepAlive, cfg.LogSampleRate)
+	}
+	if cfg.Runtime.CacheTTL != time.Minute {
+		t.Errorf("Runtime.CacheTTL = %v, want 1m", cfg.Runtime.CacheTTL)
+	}
+}
+
+func TestLoadConfigRejectsInvalidSettings(t *testing.T) {
+	tests := []struct {
+		name, key, value string
+	}{
+		{"missing project", "PROJECT_ID", ""},
+		{"request timeout", "REQUEST_TIMEOUT", "soon"},
+		{"route timeout", "ROUTE_TIMEOUTS", "/search=-1s"},
+		{"cache entries", "CACHE_MAX_ENTRIES", "0"},
+		{"concurrency", "FIRESTORE_MAX_CONCURRENCY", "many"},
+		{"proxy", "FIRESTORE_PROXY_URL", "proxy:3128"},
+		{"keep-alive", "FIRESTORE_KEEPALIVE", "15"},
+		{"sample rate", "LOG_SAMPLE_RATE", "0"},
+		{"runtime setting", "MAX_PAGES", "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"PROJECT_ID": "project", "DATABASE_ID": "(default)", tt.key: tt.value}
+			if _, err := LoadConfig(env); err == nil {
+				t.Fatalf("LoadConfig accepted %s=%q", tt.key, tt.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Duration is a time.Duration that unmarshals from strings such as "30s".
+type Duration time.Duration
+
+// UnmarshalJSON parses a Go duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %v", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// CollectionConfig holds per-collection settings from the field config file.
+type CollectionConfig struct {
+	CacheTTL  *Duration         `json:"cacheTTL"`
+	Derive    map[string]string `json:"derive"`
+	Rename    map[string]string `json:"rename"`
+	TimeField string            `json:"timeField"`
+}
+
+// FieldConfig is the JSON field config file: per-collection settings keyed by
+// collection ID, and canonical field names with the alternative names older
+// documents use for them.
+type FieldConfig struct {
+	Collections map[string]CollectionConfig `json:"collections"`
+	Canonical   map[string][]string         `json:"canonical"`
+}
+
+// LoadFieldConfig reads the field config file at path. An empty path yields an
+// empty config.
+func LoadFieldConfig(path string) (*FieldConfig, error) {
+	cfg := &FieldConfig{Collections: map[string]CollectionConfig{}}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read field config: %v", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse field config %s: %v", path, err)
+	}
+	if cfg.Collections == nil {
+		cfg.Collections = map[string]CollectionConfig{}
+	}
+	if _, err := cfg.canonicalNames(); err != nil {
+		return nil, fmt.Errorf("invalid field config %s: %v", path, err)
+	}
+	if err := cfg.checkRenames(); err != nil {
+		return nil, fmt.Errorf("invalid field config %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// checkRenames rejects a collection renaming two keys to the same name, which
+// would leave one of their values to chance.
+func (f *FieldConfig) checkRenames() error {
+	collections := make([]string, 0, len(f.Collections))
+	for collection := range f.Collections {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+
+	for _, collection := range collections {
+		renames := f.Collections[collection].Rename
+		sources := make([]string, 0, len(renames))
+		for source := range renames {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+
+		targets := make(map[string]string)
+		for _, source := range sources {
+			to := renames[source]
+			if other, ok := targets[to]; ok {
+				return fmt.Errorf("collections.%s.rename: %q and %q are both renamed to %q", collection, other, source, to)
+			}
+			targets[to] = source
+		}
+	}
+	return nil
+}
+
+// CacheTTL returns the cache TTL for a collection, or fallback when the
+// collection has no cacheTTL entry.
+func (f *FieldConfig) CacheTTL(collection string, fallback time.Duration) time.Duration {
+	if entry, ok := f.Collections[collection]; ok && entry.CacheTTL != nil {
+		return time.Duration(*entry.CacheTTL)
+	}
+	return fallback
+}
+
+// Derived returns the derived field templates configured for a collection,
+// keyed by field name.
+func (f *FieldConfig) Derived(collection string) map[string]string {
+	return f.Collections[collection].Derive
+}
+
+// TimeField returns the timestamp field configured as a collection's time
+// column, or fallback when the collection has no timeField entry.
+func (f *FieldConfig) TimeField(collection, fallback string) string {
+	if field := f.Collections[collection].TimeField; field != "" {
+		return field
+	}
+	return fallback
+}
+
+// Renames returns the field key renames configured for a collection.
+func (f *FieldConfig) Renames(collection string) map[string]string {
+	return f.Collections[collection].Rename
+}
+
+// CanonicalNames maps each alternative field name to its canonical name.
+func (f *FieldConfig) CanonicalNames() map[string]string {
+	names, _ := f.canonicalNames()
+	return names
+}
+
+// canonicalNames inverts Canonical, rejecting an alternative name listed under
+// two canonical names.
+func (f *FieldConfig) canonicalNames() (map[string]string, error) {
+	if len(f.Canonical) == 0 {
+		return nil, nil
+	}
+	names := make(map[string]string)
+	for canonical, alternatives := range f.Canonical {
+		for _, alternative := range alternatives {
+			if other, ok := names[alternative]; ok && other != canonical {
+				return nil, fmt.Errorf("canonical: %q is listed under both %q and %q", alternative, other, canonical)
+			}
+			if alternative != canonical {
+				names[alternative] = canonical
+			}
+		}
+	}
+	return names, nil
+}
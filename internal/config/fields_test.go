@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFieldConfig writes a field config file to a temporary directory.
+func writeFieldConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fields.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFieldConfigRejectsConflicts(t *testing.T) {
+	tests := []struct {
+		name, contents, want string
+	}{
+		{
+			"rename target shared",
+			`{"collections": {"orders": {"rename": {"storeCode": "store", "store_code": "store"}}}}`,
+			`"storeCode" and "store_code" are both renamed to "store"`,
+		},
+		{
+			"alternative under two canonical names",
+			`{"canonical": {"store": ["storeCode"], "shop": ["storeCode"]}}`,
+			`"storeCode" is listed under both`,
+		},
+	}
+	for _, tt := range tests {
+		_, err := LoadFieldConfig(writeFieldConfig(t, tt.contents))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: LoadFieldConfig error = %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestLoadFieldConfigAcceptsChainedRenames(t *testing.T) {
+	cfg, err := LoadFieldConfig(writeFieldConfig(t, `{"collections": {"orders": {"rename": {"a": "b", "b": "c"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renames := cfg.Renames("orders"); renames["a"] != "b" || renames["b"] != "c" {
+		t.Errorf("Renames(orders) = %v", renames)
+	}
+}
+
+func TestTimeField(t *testing.T) {
+	fields, err := LoadFieldConfig(writeFieldConfig(t, `{"collections": {
+		"orders": {"timeField": "datePosted"},
+		"restaurants": {"timeField": "updateTime"},
+		"dead-letters": {"rename": {"created": "createdAt"}}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		collection, fallback, want string
+	}{
+		{"orders", "createdAt", "datePosted"},
+		{"restaurants", "createdAt", "updateTime"},
+		{"dead-letters", "createdAt", "createdAt"},
+		{"unconfigured", "", ""},
+	}
+	for _, tt := range tests {
+		if got := fields.TimeField(tt.collection, tt.fallback); got != tt.want {
+			t.Errorf("TimeField(%q, %q) = %q, want %q", tt.collection, tt.fallback, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// hintTypes are the types a field can be coerced to.
+var hintTypes = map[string]bool{"number": true, "bool": true, "time": true}
+
+// ParseTypeHints parses "path:type,path:type" pairs (as used by FIELD_TYPE_HINTS
+// and the coerce param) into a map of dot-notation field paths to types.
+func ParseTypeHints(raw string) (map[string]string, error) {
+	hints := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		path, kind, found := strings.Cut(pair, ":")
+		path, kind = strings.TrimSpace(path), strings.TrimSpace(kind)
+		if !found || path == "" {
+			return nil, fmt.Errorf("invalid type hint %q, expected path:type", pair)
+		}
+		if !hintTypes[kind] {
+			return nil, fmt.Errorf("invalid type %q for %s, expected number, bool or time", kind, path)
+		}
+		hints[path] = kind
+	}
+	return hints, nil
+}
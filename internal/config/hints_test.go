@@ -0,0 +1,32 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTypeHints(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"", map[string]string{}, false},
+		{"amount:number", map[string]string{"amount": "number"}, false},
+		{" total.net : number , paid:bool,", map[string]string{"total.net": "number", "paid": "bool"}, false},
+		{"createdAt:time", map[string]string{"createdAt": "time"}, false},
+		{"amount", nil, true},
+		{":number", nil, true},
+		{"amount:money", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTypeHints(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTypeHints(%q) error = %v, want error %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTypeHints(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
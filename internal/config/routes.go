@@ -0,0 +1,62 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RouteConfig exposes a collection on a path without code changes.
+type RouteConfig struct {
+	Path       string `json:"path"`
+	Collection string `json:"collection"`
+	// Type is "collection" to list a collection or "subcollection" to query
+	// every collection with that ID at any depth.
+	Type string `json:"type"`
+}
+
+// routesFile is the JSON layout of the routes config file.
+type routesFile struct {
+	Routes []RouteConfig `json:"routes"`
+}
+
+// LoadRoutes reads and validates the routes config file at path. An empty path
+// yields no routes.
+func LoadRoutes(path string) ([]RouteConfig, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routes config: %v", err)
+	}
+	var file routesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse routes config %s: %v", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range file.Routes {
+		route := &file.Routes[i]
+		if route.Type == "" {
+			route.Type = "collection"
+		}
+		if !strings.HasPrefix(route.Path, "/") {
+			return nil, fmt.Errorf("route %d: path %q must start with /", i, route.Path)
+		}
+		if route.Collection == "" {
+			return nil, fmt.Errorf("route %s: collection is required", route.Path)
+		}
+		if route.Type != "collection" && route.Type != "subcollection" {
+			return nil, fmt.Errorf("route %s: type must be collection or subcollection", route.Path)
+		}
+		if seen[route.Path] {
+			return nil, fmt.Errorf("route %s is defined more than once", route.Path)
+		}
+		seen[route.Path] = true
+	}
+
+	return file.Routes, nil
+}
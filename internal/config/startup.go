@@ -0,0 +1,80 @@
+package config
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// LogStartup logs the effective configuration of the instance as a single
+// structured line. Secret-looking settings are redacted and unset ones omitted.
+func LogStartup(cfg Config) {
+	rt := cfg.Runtime
+	collectionTTLs := make(map[string]string)
+	for name, entry := range rt.Fields.Collections {
+		if entry.CacheTTL != nil {
+			collectionTTLs[name] = time.Duration(*entry.CacheTTL).String()
+		}
+	}
+
+	line, err := json.Marshal(map[string]interface{}{
+		"project":             cfg.ProjectID,
+		"database":            cfg.DatabaseID,
+		"port":                cfg.Port,
+		"backend":             cfg.Backend,
+		"cacheTTL":            rt.CacheTTL.String(),
+		"collectionCacheTTLs": collectionTTLs,
+		"settings":            settings(rt.Env),
+	})
+	if err != nil {
+		log.Printf("Starting server (configuration could not be encoded: %v)", err)
+		return
+	}
+	log.Printf("Starting server %s", line)
+}
+
+// configSettings returns the variables read into Config, from its env tags.
+func configSettings() []string {
+	var names []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := t.Field(i).Tag.Get("env"); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// isSetting reports whether a variable is one the server reads: a Config
+// field, a reloadable setting or one of the per-collection or per-profile
+// families.
+func isSetting(name string) bool {
+	if _, ok := runtimeSettings[name]; ok {
+		return true
+	}
+	for _, prefix := range settingPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	for _, setting := range configSettings() {
+		if name == setting {
+			return true
+		}
+	}
+	return false
+}
+
+// settings returns the variables set in env that the server reads, with
+// secret values redacted.
+func settings(env map[string]string) map[string]string {
+	values := make(map[string]string)
+	for name, value := range env {
+		if isSetting(name) {
+			values[name] = redactedValue(name, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"crossfire-grafana/internal/config"
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminReloadHandler re-reads .env and the field config file and swaps in the
+// new configuration. Requests already in flight keep their snapshot.
+func AdminReloadHandler(c *gin.Context) {
+	changes, err := config.Reload(".env")
+	if err != nil {
+		respond(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(changes) == 0 {
+		log.Println("Configuration reloaded: no changes")
+	}
+	for _, change := range changes {
+		log.Printf("Configuration reloaded: %s", change)
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"message": "Configuration reloaded",
+		"changes": append([]string{}, changes...),
+	})
+}
+
+// AdminCachePurgeHandler empties the collection cache, or only the entries of
+// ?collection, so out-of-band changes are read on the next request. A full
+// purge also clears the cached /search results.
+func AdminCachePurgeHandler(c *gin.Context, backend services.FirestoreBackend) {
+	collection := c.Query("collection")
+
+	purged := 0
+	if cached, ok := backend.(services.CachedBackend); ok {
+		purged = cached.Purge(collection)
+	}
+	if collection == "" {
+		purged += searchCache.Clear()
+	}
+	if collection == "" {
+		log.Printf("Cache purged: %d entries", purged)
+	} else {
+		log.Printf("Cache purged for %s: %d entries", collection, purged)
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"message":    "Cache purged",
+		"collection": collection,
+		"purged":     purged,
+	})
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"crossfire-grafana/internal/config"
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// simpleJSONAnnotationQuery is the body of a Grafana SimpleJSON /annotations request.
+type simpleJSONAnnotationQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Annotation map[string]interface{} `json:"annotation"`
+}
+
+// SimpleJSONAnnotationsHandler returns the dead letters created in the query
+// range as Grafana annotations, titled with their error message and tagged
+// with the store codes and states of their store orders. Dead letters are read
+// from the YYYY-MM-DD subcollection of each day in the range under the
+// documents of DEADLETTERS_PARENT, as /dead-letters/by-date counts them. A
+// range spanning more than DEADLETTERS_MAX_DAYS days is rejected with 400.
+func SimpleJSONAnnotationsHandler(c *gin.Context, projectID, databaseID string) {
+	var query simpleJSONAnnotationQuery
+	if err := c.ShouldBindJSON(&query); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "invalid query: " + err.Error()})
+		return
+	}
+	if query.Range.To.Before(query.Range.From) {
+		respond(c, http.StatusBadRequest, gin.H{"error": "range.to must not be before range.from"})
+		return
+	}
+
+	var days []string
+	maxDays := deadLettersMaxDays(settings(c))
+	first := query.Range.From.UTC().Truncate(24 * time.Hour)
+	for day := first; !day.After(query.Range.To); day = day.AddDate(0, 0, 1) {
+		if len(days) == maxDays {
+			respond(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("range spans more than %d days (see DEADLETTERS_MAX_DAYS)", maxDays)})
+			return
+		}
+		days = append(days, day.Format(deadLetterDateLayout))
+	}
+
+	ctx, state, ok := firestoreContext(c, "annotations")
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	parents, err := services.SubcollectionParents(ctx, projectID, databaseID, deadLettersParent(settings(c)))
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+
+	// Each day is read under every document holding it, so same-named
+	// subcollections elsewhere in the database are not matched
+	var specs []services.QuerySpec
+	for _, day := range days {
+		for _, path := range parents[day] {
+			specs = append(specs, services.QuerySpec{Parent: path, Collection: day})
+		}
+	}
+
+	fetched := make([][]services.FirestoreDocument, len(specs))
+	errs := make([]error, len(specs))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, summaryConcurrency(settings(c)))
+	for i, spec := range specs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, spec services.QuerySpec) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fetched[i], errs[i] = services.RunStructuredQuery(ctx, projectID, databaseID, spec)
+		}(i, spec)
+	}
+	wg.Wait()
+
+	timeField := annotationsTimeField(settings(c))
+	annotations := []gin.H{}
+	for i := range specs {
+		// Return the days fetched so far once the call budget runs out
+		if errors.Is(errs[i], services.ErrBudgetExceeded) {
+			continue
+		}
+		if errs[i] != nil {
+			respondFirestoreError(c, errs[i])
+			return
+		}
+		for _, doc := range fetched[i] {
+			t, ok := services.DocumentTime(doc, timeField)
+			if !ok {
+				t = doc.CreateTime
+			}
+			if t.Before(query.Range.From) || t.After(query.Range.To) {
+				continue
+			}
+			annotation := deadLetterAnnotation(doc, t)
+			if query.Annotation != nil {
+				annotation["annotation"] = query.Annotation
+			}
+			annotations = append(annotations, annotation)
+		}
+	}
+	sort.SliceStable(annotations, func(i, j int) bool {
+		return annotations[i]["time"].(int64) < annotations[j]["time"].(int64)
+	})
+
+	state.markHeaders(c)
+	respond(c, http.StatusOK, annotations)
+}
+
+// deadLetterAnnotation renders a dead letter as an annotation at t. Its tags
+// are the distinct store codes and states of its store orders, in order.
+func deadLetterAnnotation(doc services.FirestoreDocument, t time.Time) gin.H {
+	decoded, _ := services.DecodeFields(doc.Fields)
+	storeOrders, _ := valueAt(decoded, "originalPayload.StoreOrders").([]interface{})
+
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, storeOrder := range storeOrders {
+		order, _ := storeOrder.(map[string]interface{})
+		for _, tag := range []string{stringAt(order, "BillTo.StoreCode"), stringAt(order, "BillTo.State")} {
+			if tag != "" && !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	return gin.H{
+		"time":  t.UnixMilli(),
+		"title": stringAt(decoded, "errorMessage"),
+		"tags":  tags,
+	}
+}
+
+// annotationsTimeField returns the dead-letter field holding the time an
+// annotation is placed at: the time field configured for the dead letters'
+// root collection, ANNOTATIONS_TIME_FIELD, or "createdAt".
+func annotationsTimeField(rt *config.Runtime) string {
+	fallback := rt.Getenv("ANNOTATIONS_TIME_FIELD")
+	if fallback == "" {
+		fallback = "createdAt"
+	}
+	root, _, _ := strings.Cut(deadLettersParent(rt), "/")
+	return rt.Fields.TimeField(root, fallback)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// deadLetterFixture returns a dead letter document in Firestore's typed JSON,
+// created at createdAt with one store order per store code and state pair.
+func deadLetterFixture(name, createdAt, message string, stores ...[2]string) map[string]interface{} {
+	var orders []interface{}
+	for _, store := range stores {
+		orders = append(orders, map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+			"BillTo": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+				"StoreCode": map[string]interface{}{"stringValue": store[0]},
+				"State":     map[string]interface{}{"stringValue": store[1]},
+			}}},
+		}}})
+	}
+	return map[string]interface{}{
+		"name": "projects/p/databases/d/documents/" + name,
+		"fields": map[string]interface{}{
+			"createdAt":    map[string]interface{}{"timestampValue": createdAt},
+			"errorMessage": map[string]interface{}{"stringValue": message},
+			"originalPayload": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+				"StoreOrders": map[string]interface{}{"arrayValue": map[string]interface{}{"values": orders}},
+			}}},
+		},
+	}
+}
+
+func TestSimpleJSONAnnotations(t *testing.T) {
+	subcollections := map[string][]string{
+		"dead-letters/NANALL":  {"2024-12-15", "2024-12-16"},
+		"dead-letters/NANALL2": {"2024-12-16", "notes"},
+	}
+	deadLetters := map[string][]map[string]interface{}{
+		"dead-letters/NANALL/2024-12-15":  {deadLetterFixture("dead-letters/NANALL/2024-12-15/a", "2024-12-15T13:00:00Z", "late", [2]string{"S1", "NSW"})},
+		"dead-letters/NANALL/2024-12-16":  {deadLetterFixture("dead-letters/NANALL/2024-12-16/b", "2024-12-16T10:00:00Z", "boom", [2]string{"S1", "NSW"}, [2]string{"S2", "NSW"})},
+		"dead-letters/NANALL2/2024-12-16": {deadLetterFixture("dead-letters/NANALL2/2024-12-16/c", "2024-12-16T08:00:00Z", "bad store", [2]string{"S3", "VIC"})},
+	}
+	var mu sync.Mutex
+	var queried []string
+	fakeFirestore(t, func(w http.ResponseWriter, r *http.Request) {
+		_, path, _ := strings.Cut(r.URL.Path, "/documents")
+		path = strings.TrimPrefix(path, "/")
+		if document, ok := strings.CutSuffix(path, ":listCollectionIds"); ok {
+			ids, _ := json.Marshal(subcollections[document])
+			fmt.Fprintf(w, `{"collectionIds": %s}`, ids)
+			return
+		}
+		if parent, ok := strings.CutSuffix(path, ":runQuery"); ok {
+			var payload struct {
+				StructuredQuery struct {
+					From []struct {
+						CollectionID string `json:"collectionId"`
+					} `json:"from"`
+				} `json:"structuredQuery"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			collection := parent + "/" + payload.StructuredQuery.From[0].CollectionID
+			mu.Lock()
+			queried = append(queried, collection)
+			mu.Unlock()
+			var entries []map[string]interface{}
+			for _, doc := range deadLetters[collection] {
+				entries = append(entries, map[string]interface{}{"document": doc})
+			}
+			json.NewEncoder(w).Encode(entries)
+			return
+		}
+		fmt.Fprint(w, `{"documents": [
+			{"name": "projects/p/databases/d/documents/dead-letters/NANALL"},
+			{"name": "projects/p/databases/d/documents/dead-letters/NANALL2"}
+		]}`)
+	})
+
+	tests := []struct {
+		name     string
+		from, to string
+		maxDays  string
+		want     []string
+		queried  int
+	}{
+		{"one day", "2024-12-16T00:00:00Z", "2024-12-16T23:59:59Z", "", []string{
+			"2024-12-16T08:00:00Z bad store [S3 VIC]",
+			"2024-12-16T10:00:00Z boom [S1 NSW S2]",
+		}, 2},
+		{"across days", "2024-12-15T12:00:00Z", "2024-12-16T09:00:00Z", "", []string{
+			"2024-12-15T13:00:00Z late [S1 NSW]",
+			"2024-12-16T08:00:00Z bad store [S3 VIC]",
+		}, 3},
+		{"no dead letters", "2024-12-01T00:00:00Z", "2024-12-02T00:00:00Z", "", nil, 0},
+		{"range too long", "2024-12-14T00:00:00Z", "2024-12-16T00:00:00Z", "2", nil, 0},
+	}
+	for _, tt := range tests {
+		queried = nil
+		body := fmt.Sprintf(`{"range": {"from": %q, "to": %q}, "annotation": {"name": "dead letters"}}`, tt.from, tt.to)
+		c, w := testRequest("POST", "/annotations", strings.NewReader(body), map[string]string{"DEADLETTERS_MAX_DAYS": tt.maxDays}, nil)
+		SimpleJSONAnnotationsHandler(c, "p", "d")
+
+		if tt.maxDays != "" {
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: status = %d, want 400", tt.name, w.Code)
+			}
+			continue
+		}
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", tt.name, w.Code, w.Body.String())
+		}
+		var annotations []struct {
+			Time       int64                  `json:"time"`
+			Title      string                 `json:"title"`
+			Tags       []string               `json:"tags"`
+			Annotation map[string]interface{} `json:"annotation"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &annotations)
+		var got []string
+		for _, a := range annotations {
+			got = append(got, fmt.Sprintf("%s %s %v", time.UnixMilli(a.Time).UTC().Format(time.RFC3339), a.Title, a.Tags))
+			if a.Annotation["name"] != "dead letters" {
+				t.Errorf("%s: annotation %q does not echo the request's annotation", tt.name, a.Title)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: annotations = %q, want %q", tt.name, got, tt.want)
+		}
+		if len(queried) != tt.queried {
+			t.Errorf("%s: queried %q, want %d day subcollections under dead-letters", tt.name, queried, tt.queried)
+		}
+	}
+}
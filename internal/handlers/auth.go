@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validAPIKey reports whether the request carries the configured API_KEY in the
+// X-API-Key header. It is always false when no API_KEY is configured.
+func validAPIKey(c *gin.Context) bool {
+	expected := settings(c).Getenv("API_KEY")
+	if expected == "" {
+		return false
+	}
+	provided := c.GetHeader("X-API-Key")
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) == 1
+}
+
+// RequireAPIKey rejects requests without a valid X-API-Key with 401.
+func RequireAPIKey(c *gin.Context) {
+	if !validAPIKey(c) {
+		respond(c, http.StatusUnauthorized, gin.H{"error": "a valid X-API-Key is required"})
+		c.Abort()
+		return
+	}
+	c.Next()
+}
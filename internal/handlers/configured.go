@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+
+	"crossfire-grafana/internal/config"
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ConfiguredRouteHandler serves a route defined in the routes config file.
+func ConfiguredRouteHandler(c *gin.Context, backend services.FirestoreBackend, route config.RouteConfig, projectID, databaseID string) {
+	sortField, sortDir, err := sortParams(c, route.Collection)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := parseOutputOptions(c, route.Collection)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	containsField, substring, err := containsParam(c, route.Collection)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	modifiedSince, err := modifiedSinceParam(c)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	idsOnly, err := idsOnlyParam(c)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, state, ok := firestoreContext(c, route.Collection)
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	if idsOnly {
+		respondDocumentIDs(ctx, c, state, projectID, databaseID, route.Collection, route.Type == "subcollection")
+		return
+	}
+
+	var documents []services.FirestoreDocument
+	truncated := false
+	if route.Type == "subcollection" {
+		documents, err = backend.FetchSubcollection(ctx, route.Collection)
+	} else {
+		documents, truncated, err = backend.FetchCollection(ctx, route.Collection)
+	}
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+	if containsField != "" {
+		documents = services.FilterContains(documents, containsField, substring)
+	}
+	if !modifiedSince.IsZero() {
+		documents = services.FilterModifiedSince(documents, modifiedSince)
+	}
+	if sortField != "" {
+		services.OrderDocuments(documents, sortField, sortDir)
+	}
+
+	records, decodeErrors, err := shapeRecords(documentRows(documents), opts)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body := documentsBody("Documents fetched successfully from "+route.Collection, records, decodeErrors, opts, projectID, databaseID)
+	body["truncated"] = truncated
+	state.attach(c, body)
+	respondDocuments(c, body, len(records))
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"crossfire-grafana/internal/config"
+	"crossfire-grafana/internal/middleware"
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// requestState tracks the Firestore activity of a single request.
+type requestState struct {
+	cancel  context.CancelFunc
+	memo    *services.Memo
+	budget  *services.CallBudget
+	stale   *services.StaleFlag
+	results *services.ResultReadTime
+	trace   *services.DebugTrace
+	explain *services.QueryExplain
+	fetch   *services.FetchTimer
+	started time.Time
+	// ctx is the request's Firestore context, for the profile it selects
+	ctx context.Context
+}
+
+// settings returns the configuration snapshot the request reads its settings
+// from (see middleware.RuntimeSnapshot).
+func settings(c *gin.Context) *config.Runtime {
+	return config.FromContext(c.Request.Context())
+}
+
+// firestoreContext returns the context for the request's Firestore calls,
+// bounded by the read timeout configured for name and limited to
+// MAX_FIRESTORE_CALLS calls; backend reads repeated within the request are
+// served from a request-scoped memo. With ?readTime= (RFC3339) every read observes the
+// database as of that time; an invalid or too old readTime is rejected with
+// 400. ?profile= authenticates with the named credential profile, 400 when it
+// is not configured. With ?debug=true and a valid API key the context also records every
+// Firestore exchange, and with ?explain=true its queries are analyzed by
+// Firestore (which bills the documents scanned); either without a valid key
+// is rejected with 403. When ok is false the response has been written. Callers must defer
+// state.done().
+func firestoreContext(c *gin.Context, name string) (ctx context.Context, state *requestState, ok bool) {
+	if c.Query("debug") == "true" && !validAPIKey(c) {
+		respond(c, http.StatusForbidden, gin.H{"error": "debug requires a valid X-API-Key"})
+		return nil, nil, false
+	}
+	if c.Query("explain") == "true" && !validAPIKey(c) {
+		respond(c, http.StatusForbidden, gin.H{"error": "explain requires a valid X-API-Key"})
+		return nil, nil, false
+	}
+
+	profile := c.Query("profile")
+	if profile != "" && !services.ProfileExists(c.Request.Context(), profile) {
+		respond(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown credential profile %q", profile)})
+		return nil, nil, false
+	}
+
+	var readTime time.Time
+	if raw := c.Query("readTime"); raw != "" {
+		var err error
+		readTime, err = time.Parse(time.RFC3339Nano, raw)
+		if err == nil {
+			err = services.ValidateReadTime(c.Request.Context(), readTime, time.Now())
+		}
+		if err != nil {
+			respond(c, http.StatusBadRequest, gin.H{"error": "invalid readTime: " + err.Error()})
+			return nil, nil, false
+		}
+	}
+
+	state = &requestState{started: time.Now()}
+	ctx, state.cancel = services.WithReadTimeout(c.Request.Context(), name)
+	if !readTime.IsZero() {
+		ctx = services.WithReadTime(ctx, readTime)
+	}
+	if profile != "" {
+		ctx = services.WithProfile(ctx, profile)
+	}
+	state.memo = requestMemo(c)
+	ctx = services.WithMemo(ctx, state.memo)
+	ctx, state.budget = services.WithCallBudget(ctx, services.MaxFirestoreCalls(ctx))
+	ctx, state.stale = services.WithStaleFlag(ctx)
+	ctx, state.results = services.WithResultReadTime(ctx)
+	ctx, state.fetch = services.WithFetchTimer(ctx)
+	state.ctx = ctx
+
+	if c.Query("debug") == "true" {
+		ctx, state.trace = services.WithDebugTrace(ctx)
+	}
+	if c.Query("explain") == "true" {
+		ctx, state.explain = services.WithQueryExplain(ctx)
+	}
+
+	return ctx, state, true
+}
+
+// done releases the request's Firestore context and its memoized reads.
+func (s *requestState) done() {
+	s.cancel()
+	s.memo.Clear()
+}
+
+// memoContextKey is the gin context key holding the request's *services.Memo.
+const memoContextKey = "firestoreMemo"
+
+// requestMemo returns the memo of backend reads for the request, creating it
+// on first use, so identical reads within one request reach Firestore once.
+func requestMemo(c *gin.Context) *services.Memo {
+	if memo, ok := c.Get(memoContextKey); ok {
+		return memo.(*services.Memo)
+	}
+	memo := services.NewMemo()
+	c.Set(memoContextKey, memo)
+	return memo
+}
+
+// attach adds the request's Firestore state to an enveloped response body:
+// meta.budgetExceeded when the call budget ran out, meta.stale when cached
+// data was served after a Firestore failure, meta.readTime when a query
+// reported the time its results were read at, meta.timings with the time
+// spent fetching from Firestore and processing, _debug when tracing and
+// _explain with the explainMetrics of each query when explaining. With a
+// credential profile, project and database name the profile's. Bodies
+// without meta, and formats other than the default that drop it, get the
+// headers of markHeaders.
+func (s *requestState) attach(c *gin.Context, body gin.H) {
+	if projectID, ok := body["project"].(string); ok {
+		databaseID, _ := body["database"].(string)
+		body["project"], body["database"] = services.ProfileDatabase(s.ctx, projectID, databaseID)
+	}
+	meta, ok := body["meta"].(gin.H)
+	if ok {
+		if s.budget.Exceeded() {
+			meta["budgetExceeded"] = true
+		}
+		if s.stale.Stale() {
+			meta["stale"] = true
+		}
+		if readTime := s.results.Value(); readTime != "" {
+			meta["readTime"] = readTime
+		}
+		fetch, processing := s.timings(c)
+		meta["timings"] = gin.H{
+			"fetchMs":      fetch.Milliseconds(),
+			"processingMs": processing.Milliseconds(),
+		}
+	}
+	if !ok || responseFormat(c) != defaultFormat {
+		s.markHeaders(c)
+	}
+	if s.trace != nil {
+		body["_debug"] = gin.H{
+			"firestoreCalls": s.trace.Calls(),
+			"totalMs":        time.Since(s.started).Milliseconds(),
+		}
+	}
+	if s.explain != nil {
+		body["_explain"] = gin.H{"queries": s.explain.Metrics()}
+	}
+}
+
+// markHeaders flags an exceeded call budget or stale data, and reports the
+// query results' readTime and the timings as Server-Timing, with response
+// headers, for endpoints that return bare arrays.
+func (s *requestState) markHeaders(c *gin.Context) {
+	fetch, processing := s.timings(c)
+	c.Header("Server-Timing", fmt.Sprintf("firestore;dur=%.1f, processing;dur=%.1f", milliseconds(fetch), milliseconds(processing)))
+	if readTime := s.results.Value(); readTime != "" {
+		c.Header("X-Firestore-Read-Time", readTime)
+	}
+	if s.budget.Exceeded() {
+		c.Header("X-Firestore-Budget-Exceeded", "true")
+	}
+	if s.stale.Stale() {
+		c.Header("X-Cache-Stale", "true")
+	}
+}
+
+// timings splits the time since the request started into the time spent
+// waiting on Firestore and the time spent processing around it, and leaves
+// them for the request log line.
+func (s *requestState) timings(c *gin.Context) (fetch, processing time.Duration) {
+	fetch = s.fetch.Elapsed()
+	processing = time.Since(s.started) - fetch
+	c.Set(middleware.TimingsKey, fmt.Sprintf("fetch=%v processing=%v", fetch.Round(time.Millisecond), processing.Round(time.Millisecond)))
+	return fetch, processing
+}
+
+// milliseconds returns d in fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"crossfire-grafana/internal/config"
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// summaryCollections returns the collections reported by /dashboard/summary.
+func summaryCollections(rt *config.Runtime) []string {
+	var collections []string
+	for _, name := range strings.Split(rt.Getenv("SUMMARY_COLLECTIONS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			collections = append(collections, name)
+		}
+	}
+	if len(collections) == 0 {
+		return []string{"restaurants"}
+	}
+	return collections
+}
+
+// summaryConcurrency returns how many collections are fetched in parallel by
+// the summary and multi-target endpoints: SUMMARY_CONCURRENCY, or 4.
+func summaryConcurrency(rt *config.Runtime) int {
+	value, err := strconv.Atoi(rt.Getenv("SUMMARY_CONCURRENCY"))
+	if err != nil || value <= 0 {
+		return 4
+	}
+	return value
+}
+
+// DashboardSummaryHandler reports the document count of each collection in
+// SUMMARY_COLLECTIONS. With ?consistent=true every read runs in one read-only
+// transaction, so all counts reflect the same point in time.
+func DashboardSummaryHandler(c *gin.Context, backend services.FirestoreBackend, projectID, databaseID string) {
+	ctx, state, ok := firestoreContext(c, "summary")
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	consistent := c.Query("consistent") == "true"
+	if consistent {
+		transaction, err := services.BeginReadOnlyTransaction(ctx, projectID, databaseID)
+		if err != nil {
+			respondFirestoreError(c, err)
+			return
+		}
+		defer func() {
+			if err := services.RollbackTransaction(c.Request.Context(), projectID, databaseID, transaction); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}()
+		ctx = services.WithTransaction(ctx, transaction)
+	}
+
+	collections := summaryCollections(settings(c))
+	counts := make([]gin.H, len(collections))
+	errs := make([]error, len(collections))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, summaryConcurrency(settings(c)))
+	for i, collection := range collections {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, collection string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			documents, truncated, err := backend.FetchCollection(ctx, collection)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			counts[i] = gin.H{"count": len(documents), "truncated": truncated}
+		}(i, collection)
+	}
+	wg.Wait()
+
+	summary := gin.H{}
+	for i, collection := range collections {
+		if errs[i] != nil {
+			respondFirestoreError(c, fmt.Errorf("%s: %w", collection, errs[i]))
+			return
+		}
+		summary[collection] = counts[i]
+	}
+
+	body := gin.H{
+		"message":     "Summary fetched successfully",
+		"collections": summary,
+		"consistent":  consistent,
+		"meta":        gin.H{"count": len(collections)},
+		"project":     projectID,
+		"database":    databaseID,
+	}
+	state.attach(c, body)
+	respond(c, http.StatusOK, body)
+}
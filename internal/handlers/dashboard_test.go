@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"crossfire-grafana/internal/services"
+)
+
+// countingBackend records the most fetches it served at the same time.
+type countingBackend struct {
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (b *countingBackend) fetch() {
+	b.mu.Lock()
+	b.inFlight++
+	if b.inFlight > b.max {
+		b.max = b.inFlight
+	}
+	b.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	b.mu.Lock()
+	b.inFlight--
+	b.mu.Unlock()
+}
+
+func (b *countingBackend) FetchCollection(ctx context.Context, collection string) ([]services.FirestoreDocument, bool, error) {
+	b.fetch()
+	return nil, false, nil
+}
+
+func (b *countingBackend) FetchSubcollection(ctx context.Context, subCollection string) ([]services.FirestoreDocument, error) {
+	b.fetch()
+	return nil, nil
+}
+
+func TestSummaryConcurrency(t *testing.T) {
+	const collections = "a,b,c,d,e,f"
+	targets := `{"targets": [{"target": "a"}, {"target": "b"}, {"target": "c"}, {"target": "d"}, {"target": "e"}, {"target": "f"}]}`
+
+	tests := []struct {
+		name        string
+		concurrency string
+		want        int
+	}{
+		{"one at a time", "1", 1},
+		{"two", "2", 2},
+		{"default", "", 4},
+		{"more than collections", "10", 6},
+	}
+	for _, tt := range tests {
+		env := map[string]string{"SUMMARY_COLLECTIONS": collections, "SUMMARY_CONCURRENCY": tt.concurrency}
+
+		summary := &countingBackend{}
+		c, w := testRequest("GET", "/dashboard/summary", nil, env, nil)
+		DashboardSummaryHandler(c, summary, "p", "d")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: summary status = %d: %s", tt.name, w.Code, w.Body.String())
+		}
+		if summary.max != tt.want {
+			t.Errorf("%s: summary fetched %d collections at once, want %d", tt.name, summary.max, tt.want)
+		}
+
+		query := &countingBackend{}
+		c, w = testRequest("POST", "/query", strings.NewReader(targets), env, nil)
+		SimpleJSONQueryHandler(c, query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: query status = %d: %s", tt.name, w.Code, w.Body.String())
+		}
+		if query.max != tt.want {
+			t.Errorf("%s: query fetched %d targets at once, want %d", tt.name, query.max, tt.want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"crossfire-grafana/internal/config"
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// deadLetterDateLayout is the format of the dead-letter date subcollection IDs.
+const deadLetterDateLayout = "2006-01-02"
+
+// defaultDeadLettersMaxDays is the span of dates counted when
+// DEADLETTERS_MAX_DAYS is not set.
+const defaultDeadLettersMaxDays = 366
+
+// DeadLettersByDateHandler counts the dead letters in each date subcollection
+// under DEADLETTERS_PARENT (or ?parent) with COUNT(*) aggregations run under
+// the documents holding them. Dates are
+// returned in chronological order, with days between the first and last date
+// that have no subcollection reported as zero. Only the DEADLETTERS_MAX_DAYS
+// days up to the latest date are counted, and truncated reports whether older
+// dates were left out.
+func DeadLettersByDateHandler(c *gin.Context, projectID, databaseID string) {
+	parent := c.DefaultQuery("parent", deadLettersParent(settings(c)))
+
+	ctx, state, ok := firestoreContext(c, "dead-letters")
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	parents, err := services.SubcollectionParents(ctx, projectID, databaseID, parent)
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+
+	var dates []time.Time
+	for id := range parents {
+		date, err := time.Parse(deadLetterDateLayout, id)
+		if err != nil {
+			continue
+		}
+		dates = append(dates, date)
+	}
+	dates, truncated := recentDates(dates, deadLettersMaxDays(settings(c)))
+
+	// Each date is counted under every document holding it, so the counts
+	// stay within parent rather than matching same-named subcollections
+	// elsewhere in the database
+	type countJob struct {
+		date time.Time
+		spec services.QuerySpec
+	}
+	var jobs []countJob
+	for _, date := range dates {
+		id := date.Format(deadLetterDateLayout)
+		for _, path := range parents[id] {
+			jobs = append(jobs, countJob{date: date, spec: services.QuerySpec{Parent: path, Collection: id}})
+		}
+	}
+
+	counts := make([]int64, len(jobs))
+	errs := make([]error, len(jobs))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, deadLettersConcurrency(settings(c)))
+	for i, job := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, spec services.QuerySpec) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			counts[i], errs[i] = services.CountDocuments(ctx, projectID, databaseID, spec)
+		}(i, job.spec)
+	}
+	wg.Wait()
+
+	byDate := make(map[time.Time]int64, len(dates))
+	for i, job := range jobs {
+		if errs[i] != nil {
+			respondFirestoreError(c, fmt.Errorf("%s: %w", job.spec.Parent+"/"+job.spec.Collection, errs[i]))
+			return
+		}
+		byDate[job.date] += counts[i]
+	}
+
+	body := envelope(settings(c), "Dead letter counts fetched successfully", dailyCounts(dates, byDate), projectID, databaseID)
+	body["parent"] = parent
+	body["truncated"] = truncated
+	state.attach(c, body)
+	respond(c, http.StatusOK, body)
+}
+
+// recentDates sorts dates and keeps those within maxDays days of the latest,
+// reporting whether any were dropped. Subcollection IDs come from the data
+// (and the parent from the request), so without this bound dates such as
+// 0001-01-01 and 9999-12-31 would zero-fill millions of rows.
+func recentDates(dates []time.Time, maxDays int) ([]time.Time, bool) {
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	if len(dates) == 0 {
+		return dates, false
+	}
+	earliest := dates[len(dates)-1].AddDate(0, 0, 1-maxDays)
+	start := sort.Search(len(dates), func(i int) bool { return !dates[i].Before(earliest) })
+	return dates[start:], start > 0
+}
+
+// dailyCounts returns a row per day from the first to the last of the sorted
+// dates, with the count of byDate or zero.
+func dailyCounts(dates []time.Time, byDate map[time.Time]int64) []Row {
+	rows := []Row{}
+	if len(dates) == 0 {
+		return rows
+	}
+	for date := dates[0]; !date.After(dates[len(dates)-1]); date = date.AddDate(0, 0, 1) {
+		rows = append(rows, Row{"date": date.Format(deadLetterDateLayout), "count": byDate[date]})
+	}
+	return rows
+}
+
+// deadLettersMaxDays returns DEADLETTERS_MAX_DAYS, the span of dates
+// /dead-letters/by-date counts, or defaultDeadLettersMaxDays.
+func deadLettersMaxDays(rt *config.Runtime) int {
+	value, err := strconv.Atoi(rt.Getenv("DEADLETTERS_MAX_DAYS"))
+	if err != nil || value <= 0 {
+		return defaultDeadLettersMaxDays
+	}
+	return value
+}
+
+// deadLettersParent returns the collection or document holding the dead-letter
+// date subcollections: DEADLETTERS_PARENT, or "dead-letters".
+func deadLettersParent(rt *config.Runtime) string {
+	if parent := rt.Getenv("DEADLETTERS_PARENT"); parent != "" {
+		return parent
+	}
+	return "dead-letters"
+}
+
+// storeOrderKey identifies an expanded store-order row: the dead letter's order
+// number and the store order's index within it, with the document ID breaking
+// ties between dead letters sharing an order number.
+type storeOrderKey struct {
+	OrderNumber string
+	Index       int
+	DocumentID  string
+}
+
+// less orders keys by order number, then index, then document ID.
+func (k storeOrderKey) less(other storeOrderKey) bool {
+	if k.OrderNumber != other.OrderNumber {
+		return k.OrderNumber < other.OrderNumber
+	}
+	if k.Index != other.Index {
+		return k.Index < other.Index
+	}
+	return k.DocumentID < other.DocumentID
+}
+
+// encode returns the key as an opaque cursor.
+func (k storeOrderKey) encode() string {
+	encoded, _ := json.Marshal([]interface{}{k.OrderNumber, k.Index, k.DocumentID})
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeCursor parses a cursor returned as meta.nextCursor.
+func decodeCursor(cursor string) (storeOrderKey, error) {
+	var key storeOrderKey
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return key, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	var parts []interface{}
+	if err := json.Unmarshal(raw, &parts); err != nil || len(parts) != 3 {
+		return key, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	orderNumber, ok1 := parts[0].(string)
+	index, ok2 := parts[1].(float64)
+	documentID, ok3 := parts[2].(string)
+	if !ok1 || !ok2 || !ok3 {
+		return key, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return storeOrderKey{OrderNumber: orderNumber, Index: int(index), DocumentID: documentID}, nil
+}
+
+// keyedRow is an expanded store-order row with its pagination key.
+type keyedRow struct {
+	key storeOrderKey
+	row Row
+}
+
+// storeOrderPage reads the optional ?limit and ?cursor params of the dead
+// letters endpoint. paginate is false when neither is given.
+type storeOrderPage struct {
+	paginate bool
+	limit    int
+	after    *storeOrderKey
+}
+
+// parseStoreOrderPage validates ?limit and ?cursor.
+func parseStoreOrderPage(c *gin.Context) (storeOrderPage, error) {
+	var page storeOrderPage
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return page, fmt.Errorf("limit must be a positive integer")
+		}
+		page.paginate = true
+		page.limit = limit
+	}
+	if raw := c.Query("cursor"); raw != "" {
+		key, err := decodeCursor(raw)
+		if err != nil {
+			return page, err
+		}
+		page.paginate = true
+		page.after = &key
+	}
+	return page, nil
+}
+
+// apply orders rows by key and returns those after the cursor, at most limit
+// of them, with the cursor of the next page ("" on the last page).
+func (p storeOrderPage) apply(rows []keyedRow) ([]Row, string) {
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].key.less(rows[j].key) })
+
+	start := 0
+	if p.after != nil {
+		start = sort.Search(len(rows), func(i int) bool { return p.after.less(rows[i].key) })
+	}
+	rows = rows[start:]
+
+	next := ""
+	if p.limit > 0 && len(rows) > p.limit {
+		rows = rows[:p.limit]
+		next = rows[len(rows)-1].key.encode()
+	}
+
+	page := make([]Row, len(rows))
+	for i, keyed := range rows {
+		page[i] = keyed.row
+	}
+	return page, next
+}
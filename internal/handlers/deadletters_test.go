@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+// day parses a YYYY-MM-DD date.
+func day(t *testing.T, value string) time.Time {
+	t.Helper()
+	date, err := time.Parse(deadLetterDateLayout, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return date
+}
+
+func TestRecentDates(t *testing.T) {
+	tests := []struct {
+		dates         []string
+		maxDays       int
+		want          []string
+		wantTruncated bool
+	}{
+		{nil, 366, nil, false},
+		{[]string{"2025-01-03", "2025-01-01"}, 366, []string{"2025-01-01", "2025-01-03"}, false},
+		{[]string{"2025-01-01", "2025-01-03"}, 3, []string{"2025-01-01", "2025-01-03"}, false},
+		{[]string{"2025-01-01", "2025-01-03"}, 2, []string{"2025-01-03"}, true},
+		{[]string{"0001-01-01", "9999-12-31"}, 366, []string{"9999-12-31"}, true},
+	}
+	for _, tt := range tests {
+		var dates []time.Time
+		for _, value := range tt.dates {
+			dates = append(dates, day(t, value))
+		}
+		got, truncated := recentDates(dates, tt.maxDays)
+		var values []string
+		for _, date := range got {
+			values = append(values, date.Format(deadLetterDateLayout))
+		}
+		if len(values) != len(tt.want) || truncated != tt.wantTruncated {
+			t.Errorf("recentDates(%q, %d) = %q, %v; want %q, %v", tt.dates, tt.maxDays, values, truncated, tt.want, tt.wantTruncated)
+			continue
+		}
+		for i := range values {
+			if values[i] != tt.want[i] {
+				t.Errorf("recentDates(%q, %d) = %q, want %q", tt.dates, tt.maxDays, values, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestDailyCounts(t *testing.T) {
+	dates := []time.Time{day(t, "2025-01-30"), day(t, "2025-02-02")}
+	rows := dailyCounts(dates, map[time.Time]int64{dates[0]: 4, dates[1]: 1})
+
+	want := []struct {
+		date  string
+		count int64
+	}{{"2025-01-30", 4}, {"2025-01-31", 0}, {"2025-02-01", 0}, {"2025-02-02", 1}}
+	if len(rows) != len(want) {
+		t.Fatalf("dailyCounts = %v, want %d rows", rows, len(want))
+	}
+	for i, w := range want {
+		if rows[i]["date"] != w.date || rows[i]["count"] != w.count {
+			t.Errorf("row %d = %v, want %s with %d", i, rows[i], w.date, w.count)
+		}
+	}
+	if rows := dailyCounts(nil, nil); rows == nil || len(rows) != 0 {
+		t.Errorf("dailyCounts(nil) = %#v, want an empty slice", rows)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"crossfire-grafana/internal/config"
+)
+
+// derivedField is a record field computed from a template such as
+// "{orderNumber} - {billTo.state}".
+type derivedField struct {
+	name     string
+	template string
+}
+
+// templatePlaceholder matches {field} placeholders in a derived field or
+// series alias template.
+var templatePlaceholder = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// reservedRecordKeys are record keys a derived field may not replace.
+var reservedRecordKeys = map[string]bool{"id": true, "name": true, "fields": true}
+
+// parseDerivedFields merges the collection's configured derived fields with
+// the request's derive=name=template params; a param replaces a configured
+// field of the same name.
+func parseDerivedFields(rt *config.Runtime, collection string, params []string) ([]derivedField, error) {
+	templates := make(map[string]string)
+	for name, template := range rt.Fields.Derived(collection) {
+		templates[name] = template
+	}
+	for _, param := range params {
+		name, template, found := strings.Cut(param, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || template == "" {
+			return nil, fmt.Errorf("invalid derive %q, expected name=template", param)
+		}
+		templates[name] = template
+	}
+
+	var fields []derivedField
+	for name, template := range templates {
+		if reservedRecordKeys[name] {
+			return nil, fmt.Errorf("derived field %q would replace a record key", name)
+		}
+		fields = append(fields, derivedField{name: name, template: template})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	return fields, nil
+}
+
+// deriveFields adds each derived field to the record, rendered from its
+// decoded Firestore fields.
+func deriveFields(record Row, decoded map[string]interface{}, fields []derivedField) {
+	id, _ := record["id"].(string)
+	for _, field := range fields {
+		record[field.name] = renderTemplate(field.template, decoded, map[string]string{"id": id})
+	}
+}
+
+// renderTemplate replaces {field.path} placeholders with the decoded value at
+// that path, or with the matching entry of extra. Missing values render empty.
+func renderTemplate(template string, decoded map[string]interface{}, extra map[string]string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(template, func(match string) string {
+		field := strings.Trim(match, "{}")
+		if value, ok := extra[field]; ok {
+			return value
+		}
+		return stringAt(decoded, field)
+	})
+}
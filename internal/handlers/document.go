@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// DocumentHandler returns a single document by path. ?fields=a,b.c limits the
+// returned fields with a Firestore document mask. The document's updateTime is
+// sent as Last-Modified, and an If-Modified-Since request for a document that
+// has not changed since gets 304.
+func DocumentHandler(c *gin.Context, projectID, databaseID string) {
+	documentPath := strings.Trim(c.Param("path"), "/")
+	if _, err := services.DocumentPath(documentPath); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	collection, _, _ := strings.Cut(documentPath, "/")
+
+	var fields []string
+	for _, field := range strings.Split(c.Query("fields"), ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		if _, err := services.EscapeFieldPath(field); err != nil {
+			respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		fields = append(fields, field)
+	}
+	opts, err := parseOutputOptions(c, collection)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, state, ok := firestoreContext(c, collection)
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	document, err := services.FetchDocument(ctx, projectID, databaseID, documentPath, fields)
+	if errors.Is(err, services.ErrDocumentNotFound) {
+		respond(c, http.StatusNotFound, gin.H{"error": "document " + documentPath + " not found"})
+		return
+	}
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+	if notModified(c, document.UpdateTime) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	records, decodeErrors, err := shapeRecords(documentRows([]services.FirestoreDocument{document}), opts)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// With a single document there is no partial result to return
+	if len(decodeErrors) > 0 {
+		respond(c, http.StatusBadRequest, gin.H{"error": "failed to decode " + decodeErrors[0].Name + ": " + decodeErrors[0].Error})
+		return
+	}
+
+	body := gin.H{
+		"message":  "Document fetched successfully",
+		"document": records[0],
+		"project":  projectID,
+		"database": databaseID,
+	}
+	state.attach(c, body)
+	respond(c, http.StatusOK, body)
+}
+
+// notModified sets Last-Modified from updateTime and reports whether the
+// request's If-Modified-Since shows the client already has this version.
+// HTTP dates have second precision, so updateTime is compared truncated.
+func notModified(c *gin.Context, updateTime time.Time) bool {
+	if updateTime.IsZero() {
+		return false
+	}
+	c.Header("Last-Modified", updateTime.UTC().Format(http.TimeFormat))
+
+	since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !updateTime.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"crossfire-grafana/internal/config"
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Formatter renders successful responses in one output format. Handlers build
+// their usual body and respond picks the formatter, so a format is added by
+// registering it rather than by changing handlers.
+type Formatter interface {
+	// MediaTypes lists the Accept media types the format is negotiated for.
+	MediaTypes() []string
+	// Flattens reports whether records should be flattened before rendering.
+	Flattens() bool
+	// Format writes body with the status code.
+	Format(c *gin.Context, code int, body interface{})
+}
+
+// defaultFormat names the formatter used when neither ?format= nor Accept
+// selects another. It renders bodies as they are.
+const defaultFormat = "json"
+
+// formatters is the registry of formatters by format name.
+var formatters = map[string]Formatter{}
+
+// registerFormatter adds a formatter under name, replacing any previous one.
+func registerFormatter(name string, formatter Formatter) {
+	formatters[name] = formatter
+}
+
+func init() {
+	registerFormatter(defaultFormat, jsonFormatter{})
+	registerFormatter("raw", rawFormatter{})
+	registerFormatter("csv", csvFormatter{})
+	registerFormatter("ndjson", ndjsonFormatter{})
+	registerFormatter("grafana-table", tableFormatter{})
+	registerFormatter("simplejson", simpleJSONFormatter{})
+	registerFormatter("infinity", infinityFormatter{})
+}
+
+// responseFormat returns the name of the response's format: ?format= when it
+// names a registered formatter, otherwise the one negotiated from Accept.
+func responseFormat(c *gin.Context) string {
+	if format := c.Query("format"); formatters[format] != nil {
+		return format
+	}
+	return negotiateFormat(c.GetHeader("Accept"))
+}
+
+// negotiateFormat picks the format of the supported media type with the
+// highest quality in an Accept header; ties go to the one listed first, and
+// the default format is used when none is supported.
+func negotiateFormat(accept string) string {
+	byMediaType := make(map[string]string)
+	for name, formatter := range formatters {
+		for _, mediaType := range formatter.MediaTypes() {
+			byMediaType[mediaType] = name
+		}
+	}
+
+	type candidate struct {
+		format  string
+		quality float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		format, ok := byMediaType[strings.ToLower(strings.TrimSpace(mediaType))]
+		if !ok {
+			continue
+		}
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name == "q" {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality > 0 {
+			candidates = append(candidates, candidate{format, quality})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].quality > candidates[j].quality })
+	if len(candidates) == 0 {
+		return defaultFormat
+	}
+	return candidates[0].format
+}
+
+// responseRecords returns the records a body holds: the records of an
+// envelope, the elements of an array, or else the body itself. Elements that
+// are not objects become {"value": element}.
+func responseRecords(rt *config.Runtime, obj interface{}) []Row {
+	if body, ok := obj.(gin.H); ok {
+		if records, ok := body[responseDataKey(rt)]; ok {
+			obj = records
+		}
+	}
+
+	value := reflect.ValueOf(obj)
+	if value.Kind() != reflect.Slice {
+		return []Row{toRow(obj)}
+	}
+	records := make([]Row, value.Len())
+	for i := range records {
+		records[i] = toRow(value.Index(i).Interface())
+	}
+	return records
+}
+
+// toRow converts a response element into a record.
+func toRow(element interface{}) Row {
+	switch v := element.(type) {
+	case Row:
+		return v
+	case gin.H:
+		return Row(v)
+	case map[string]interface{}:
+		return Row(v)
+	}
+	return Row{"value": element}
+}
+
+// jsonFormatter renders the body as JSON, or JSONP with ?callback.
+type jsonFormatter struct{}
+
+func (jsonFormatter) MediaTypes() []string {
+	return []string{"application/json", "application/*", "*/*"}
+}
+
+func (jsonFormatter) Flattens() bool { return false }
+
+func (jsonFormatter) Format(c *gin.Context, code int, body interface{}) {
+	writeJSON(c, code, body)
+}
+
+// rawFormatter renders just the body's records as a JSON array, without the
+// envelope.
+type rawFormatter struct{}
+
+func (rawFormatter) MediaTypes() []string { return nil }
+
+func (rawFormatter) Flattens() bool { return false }
+
+func (rawFormatter) Format(c *gin.Context, code int, body interface{}) {
+	writeJSON(c, code, responseRecords(settings(c), body))
+}
+
+// infinityFormatter renders the records as a JSON array of flat objects, one
+// key per flattened field, as Grafana's Infinity datasource reads them.
+type infinityFormatter struct{}
+
+func (infinityFormatter) MediaTypes() []string { return nil }
+
+func (infinityFormatter) Flattens() bool { return true }
+
+func (infinityFormatter) Format(c *gin.Context, code int, body interface{}) {
+	records := responseRecords(settings(c), body)
+	rows := make([]map[string]interface{}, len(records))
+	for i, record := range records {
+		rows[i] = flatRow(record)
+	}
+	writeJSON(c, code, rows)
+}
+
+// tableFormatter renders the records as a Grafana table (see toGrafanaTable).
+type tableFormatter struct{}
+
+func (tableFormatter) MediaTypes() []string {
+	return []string{"application/vnd.grafana.table+json"}
+}
+
+func (tableFormatter) Flattens() bool { return true }
+
+func (tableFormatter) Format(c *gin.Context, code int, body interface{}) {
+	writeJSON(c, code, toGrafanaTable(responseRecords(settings(c), body)))
+}
+
+// simpleJSONFormatter renders the records as the table response of Grafana's
+// SimpleJSON datasource: an array holding one Grafana table.
+type simpleJSONFormatter struct{}
+
+func (simpleJSONFormatter) MediaTypes() []string { return nil }
+
+func (simpleJSONFormatter) Flattens() bool { return true }
+
+func (simpleJSONFormatter) Format(c *gin.Context, code int, body interface{}) {
+	writeJSON(c, code, []gin.H{toGrafanaTable(responseRecords(settings(c), body))})
+}
+
+// csvFormatter renders the records as CSV: a header of the flattened keys,
+// ordered as by format=columns, then one line per record.
+type csvFormatter struct{}
+
+func (csvFormatter) MediaTypes() []string { return []string{"text/csv"} }
+
+func (csvFormatter) Flattens() bool { return true }
+
+func (csvFormatter) Format(c *gin.Context, code int, body interface{}) {
+	c.Render(code, csvBody{Records: responseRecords(settings(c), body)})
+}
+
+// csvBody renders records as CSV.
+type csvBody struct {
+	Records []Row
+}
+
+// Render writes the CSV body.
+func (r csvBody) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	rows := make([]map[string]interface{}, 0, len(r.Records))
+	for _, record := range r.Records {
+		flat, err := services.Flatten(flatRow(record), services.FlattenOptions{})
+		if err != nil {
+			return err
+		}
+		rows = append(rows, flat)
+	}
+
+	keys := columnKeys(rows)
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(keys); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		line := make([]string, len(keys))
+		for i, key := range keys {
+			line[i] = csvCell(row[key])
+		}
+		if err := writer.Write(line); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteContentType sets the CSV content type.
+func (r csvBody) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+}
+
+// csvCell renders a flattened value as a CSV cell: empty for null, RFC3339 for
+// times and JSON for the empty maps and arrays flattening leaves behind.
+func csvCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case map[string]interface{}, []interface{}:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+	return valueText(value)
+}
+
+// ndjsonFormatter renders the records as newline-delimited JSON.
+type ndjsonFormatter struct{}
+
+func (ndjsonFormatter) MediaTypes() []string { return []string{"application/x-ndjson"} }
+
+func (ndjsonFormatter) Flattens() bool { return false }
+
+// Format writes the records as NDJSON, or with ?partitionBy=<field> as a zip
+// holding one NDJSON file per value of the field. A field with more than
+// NDJSON_MAX_PARTITIONS values is rejected with 400.
+func (ndjsonFormatter) Format(c *gin.Context, code int, body interface{}) {
+	records := responseRecords(settings(c), body)
+	field := c.Query("partitionBy")
+	if field == "" {
+		c.Render(code, ndjsonBody{Records: records})
+		return
+	}
+	partitions := partitionRecords(records, field)
+	if limit := maxPartitions(settings(c)); len(partitions) > limit {
+		writeJSON(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("partitionBy %s has more than %d values (see NDJSON_MAX_PARTITIONS)", field, limit)})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="partitions.zip"`)
+	c.Render(code, partitionedNDJSONBody{Records: records, Partitions: partitions})
+}
+
+// ndjsonBody renders records as newline-delimited JSON, one record per line.
+type ndjsonBody struct {
+	Records []Row
+}
+
+// Render writes the NDJSON body.
+func (r ndjsonBody) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range r.Records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteContentType sets the NDJSON content type.
+func (r ndjsonBody) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+}
+
+// defaultMaxPartitions is the number of partitions allowed when
+// NDJSON_MAX_PARTITIONS is not set.
+const defaultMaxPartitions = 100
+
+// maxPartitions returns NDJSON_MAX_PARTITIONS, the most files a partitioned
+// NDJSON export may hold, or defaultMaxPartitions.
+func maxPartitions(rt *config.Runtime) int {
+	value, err := strconv.Atoi(rt.Getenv("NDJSON_MAX_PARTITIONS"))
+	if err != nil || value <= 0 {
+		return defaultMaxPartitions
+	}
+	return value
+}
+
+// missingPartition names the file of the records without a value for the
+// partition field. Escaped values never contain parentheses, so it cannot
+// clash with a value's file.
+const missingPartition = "(none)"
+
+// recordPartition is the indexes of the records sharing a partition value.
+type recordPartition struct {
+	File    string
+	Records []int
+}
+
+// partitionRecords groups records by their (decoded) value of field, looked
+// up as a flattened key or a dotted path, into files named after the
+// path-escaped value and sorted by name. Only indexes are kept, so the
+// records are not copied.
+func partitionRecords(records []Row, field string) []recordPartition {
+	byFile := make(map[string]*recordPartition)
+	var partitions []*recordPartition
+	for i, record := range records {
+		fields, _ := record["fields"].(map[string]interface{})
+		value, ok := fields[field]
+		if !ok {
+			value = valueAt(fields, field)
+		}
+		file := missingPartition
+		if value != nil {
+			file = url.PathEscape(valueText(value))
+		}
+		partition := byFile[file]
+		if partition == nil {
+			partition = &recordPartition{File: file + ".ndjson"}
+			byFile[file] = partition
+			partitions = append(partitions, partition)
+		}
+		partition.Records = append(partition.Records, i)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].File < partitions[j].File })
+
+	sorted := make([]recordPartition, len(partitions))
+	for i, partition := range partitions {
+		sorted[i] = *partition
+	}
+	return sorted
+}
+
+// partitionedNDJSONBody renders partitioned records as a zip of NDJSON files.
+type partitionedNDJSONBody struct {
+	Records    []Row
+	Partitions []recordPartition
+}
+
+// Render streams the zip to the client, encoding each partition's records as
+// its file is written, so no more than one record's JSON is buffered.
+func (r partitionedNDJSONBody) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	archive := zip.NewWriter(w)
+	for _, partition := range r.Partitions {
+		file, err := archive.Create(partition.File)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(file)
+		for _, i := range partition.Records {
+			if err := encoder.Encode(r.Records[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return archive.Close()
+}
+
+// WriteContentType sets the zip content type.
+func (r partitionedNDJSONBody) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/zip")
+}
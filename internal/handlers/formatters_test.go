@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		accept, want string
+	}{
+		{"", "json"},
+		{"*/*", "json"},
+		{"text/html", "json"},
+		{"text/csv", "csv"},
+		{"TEXT/CSV; charset=utf-8", "csv"},
+		{"application/x-ndjson", "ndjson"},
+		{"application/vnd.grafana.table+json", "grafana-table"},
+		{"text/csv;q=0.5, application/json", "json"},
+		{"application/json;q=0.2, text/csv;q=0.8", "csv"},
+		{"text/csv, application/x-ndjson", "csv"},
+		{"text/csv;q=0, application/x-ndjson;q=0.1", "ndjson"},
+		{"text/csv;q=0", "json"},
+		{"text/csv;q=abc", "csv"},
+	}
+	for _, tt := range tests {
+		if got := negotiateFormat(tt.accept); got != tt.want {
+			t.Errorf("negotiateFormat(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestResponseFormatPrefersTheFormatParam(t *testing.T) {
+	c := queryContext(map[string][]string{"format": {"ndjson"}})
+	c.Request.Header.Set("Accept", "text/csv")
+	if got := responseFormat(c); got != "ndjson" {
+		t.Errorf("responseFormat = %q, want ndjson", got)
+	}
+
+	// A format that only shapes rows falls back to negotiation
+	c = queryContext(map[string][]string{"format": {"columns"}})
+	c.Request.Header.Set("Accept", "text/csv")
+	if got := responseFormat(c); got != "csv" {
+		t.Errorf("responseFormat = %q, want csv", got)
+	}
+}
+
+func TestFormattersRenderRecords(t *testing.T) {
+	// Records as shapeRecords leaves them for the formatters that flatten
+	openedAt := time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+	records := []Row{
+		{"id": "a", "fields": map[string]interface{}{
+			"name": "Thai Palace", "rating": int64(4), "openedAt": openedAt, "billTo.state": "NSW",
+		}},
+		{"id": "b", "fields": map[string]interface{}{"name": "Pho, House", "rating": 3.5}},
+	}
+
+	tests := []struct {
+		format, contentType, want string
+	}{
+		{"csv", "text/csv", "id,name,billTo.state,openedAt,rating\n" +
+			"a,Thai Palace,NSW,2024-12-16T10:00:00Z,4\n" +
+			"b,\"Pho, House\",,,3.5\n"},
+		{"ndjson", "application/x-ndjson",
+			`{"fields":{"billTo.state":"NSW","name":"Thai Palace","openedAt":"2024-12-16T10:00:00Z","rating":4},"id":"a"}` + "\n" +
+				`{"fields":{"name":"Pho, House","rating":3.5},"id":"b"}` + "\n"},
+		{"grafana-table", "application/json", `{"columns":[` +
+			`{"text":"id","type":"string"},{"text":"name","type":"string"},{"text":"billTo.state","type":"string"},` +
+			`{"text":"openedAt","type":"time"},{"text":"rating","type":"number"}],` +
+			`"rows":[["a","Thai Palace","NSW",1734343200000,4],["b","Pho, House",null,null,3.5]],"type":"table"}`},
+	}
+	for _, tt := range tests {
+		c, w := testRequest("GET", "/restaurants-cache?format="+tt.format, nil, nil, nil)
+		body := envelope(settings(c), "ok", records, "p", "d")
+		respond(c, http.StatusOK, body)
+
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) {
+			t.Errorf("format %s: Content-Type = %q, want %s", tt.format, got, tt.contentType)
+		}
+		if got := w.Body.String(); got != tt.want {
+			t.Errorf("format %s: body =\n%s\nwant\n%s", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestPartitionedNDJSON(t *testing.T) {
+	tests := []struct {
+		query    string
+		maxParts string
+		wantCode int
+		want     map[string][]string
+	}{
+		{"format=ndjson&partitionBy=billTo.state", "", http.StatusOK, map[string][]string{"NSW.ndjson": {"a"}, "VIC.ndjson": {"b"}}},
+		{"format=ndjson&partitionBy=open", "", http.StatusOK, map[string][]string{"(none).ndjson": {"b"}, "true.ndjson": {"a"}}},
+		{"format=ndjson&flatten=true&partitionBy=billTo.state", "", http.StatusOK, map[string][]string{"NSW.ndjson": {"a"}, "VIC.ndjson": {"b"}}},
+		{"format=ndjson&partitionBy=billTo.state", "1", http.StatusBadRequest, nil},
+		{"format=csv&partitionBy=billTo.state", "", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		c, w := testRequest("GET", "/restaurants-cache?"+tt.query, nil, map[string]string{"NDJSON_MAX_PARTITIONS": tt.maxParts}, nil)
+		RestaurantsCacheHandler(c, restaurantFixtures, "p", "d")
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: status = %d, want %d: %s", tt.query, w.Code, tt.wantCode, w.Body.String())
+			continue
+		}
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+
+		archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		got := make(map[string][]string)
+		for _, file := range archive.File {
+			f, err := file.Open()
+			if err != nil {
+				t.Fatalf("%s: %s: %v", tt.query, file.Name, err)
+			}
+			dec := json.NewDecoder(f)
+			for dec.More() {
+				var record struct{ ID string }
+				if err := dec.Decode(&record); err != nil {
+					t.Fatalf("%s: %s: %v", tt.query, file.Name, err)
+				}
+				got[file.Name] = append(got[file.Name], record.ID)
+			}
+			f.Close()
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: partitions = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
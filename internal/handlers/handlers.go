@@ -1,115 +1,832 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"crossfire-grafana/internal/cache"
+	"crossfire-grafana/internal/config"
+	"crossfire-grafana/internal/services"
+	"crossfire-grafana/internal/version"
 	"github.com/gin-gonic/gin"
-	"crossfire-grafana/internal/services" 
 )
 
 // HomeHandler handles the base route.
 func HomeHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Server is running"})
+	respond(c, http.StatusOK, gin.H{"message": "Server is running"})
 }
 
-// RestaurantsCacheHandler fetches data from the "restaurants" collection.
-func RestaurantsCacheHandler(c *gin.Context, projectID, databaseID string) {
+// VersionHandler reports the build version and the Firestore target it serves.
+func VersionHandler(c *gin.Context, projectID, databaseID string) {
+	respond(c, http.StatusOK, gin.H{
+		"version":  version.Version,
+		"project":  projectID,
+		"database": databaseID,
+	})
+}
+
+// RestaurantsCacheHandler fetches data from the "restaurants" collection. With
+// ?where= filters the collection is read with a runQuery that applies them in
+// Firestore; otherwise it is listed, through the cache.
+func RestaurantsCacheHandler(c *gin.Context, backend services.FirestoreBackend, projectID, databaseID string) {
 	restaurantsCollection := "restaurants"
 
-	documents, err := services.FetchDocumentsFromFirestore(projectID, databaseID, restaurantsCollection)
+	sortField, sortDir, err := sortParams(c, restaurantsCollection)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := parseOutputOptions(c, restaurantsCollection)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	containsField, substring, err := containsParam(c, restaurantsCollection)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	modifiedSince, err := modifiedSinceParam(c)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	idsOnly, err := idsOnlyParam(c)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	where, err := whereParams(c)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	spec := services.QuerySpec{Collection: restaurantsCollection, Where: where}
+	if _, err := spec.StructuredQuery(c.Request.Context()); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "Documents fetched successfully from restaurants",
-		"documents": documents,
-	})
+	ctx, state, ok := firestoreContext(c, "restaurants")
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	if idsOnly {
+		respondDocumentIDs(ctx, c, state, projectID, databaseID, restaurantsCollection, false)
+		return
+	}
+
+	var documents []services.FirestoreDocument
+	var truncated bool
+	if len(where) > 0 {
+		documents, err = services.RunStructuredQuery(ctx, projectID, databaseID, spec)
+	} else {
+		documents, truncated, err = backend.FetchCollection(ctx, restaurantsCollection)
+	}
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+	if containsField != "" {
+		documents = services.FilterContains(documents, containsField, substring)
+	}
+	if !modifiedSince.IsZero() {
+		documents = services.FilterModifiedSince(documents, modifiedSince)
+	}
+	if sortField != "" {
+		services.OrderDocuments(documents, sortField, sortDir)
+	}
+
+	records, decodeErrors, err := shapeRecords(documentRows(documents), opts)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body := documentsBody("Documents fetched successfully from restaurants", records, decodeErrors, opts, projectID, databaseID)
+	body["truncated"] = truncated
+	state.attach(c, body)
+	respondDocuments(c, body, len(records))
 }
 
 // LatestOrdersHandler fetches data from the "latest-orders" collection.
-func LatestOrdersHandler(c *gin.Context, projectID, databaseID string) {
+func LatestOrdersHandler(c *gin.Context, backend services.FirestoreBackend, projectID, databaseID string) {
 	subCollectionID := c.Query("subCollection")
 	if subCollectionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "subCollection query parameter is required"})
+		respond(c, http.StatusBadRequest, gin.H{"error": "subCollection query parameter is required"})
 		return
 	}
 
-	documents, err := services.FetchDocumentsFromFirestoreWithSubcollection(projectID, databaseID, subCollectionID)
+	sortField, sortDir, err := sortParams(c, subCollectionID)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := parseOutputOptions(c, subCollectionID)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	containsField, substring, err := containsParam(c, subCollectionID)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	modifiedSince, err := modifiedSinceParam(c)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	idsOnly, err := idsOnlyParam(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	var processedDocuments []map[string]interface{}
-	for _, doc := range documents {
-		fields := doc.Fields
-		var orderNumber, createdAt, datePosted string
+	ctx, state, ok := firestoreContext(c, "latest-orders")
+	if !ok {
+		return
+	}
+	defer state.done()
 
-		if orderNumberField, ok := fields["orderNumber"]; ok {
-			orderNumber = orderNumberField.(map[string]interface{})["stringValue"].(string)
-		}
-		if createdAtField, ok := fields["createdAt"]; ok {
-			createdAt = createdAtField.(map[string]interface{})["stringValue"].(string)
-		}
-		if datePostedField, ok := fields["datePosted"]; ok {
-			datePosted = datePostedField.(map[string]interface{})["stringValue"].(string)
+	if idsOnly {
+		respondDocumentIDs(ctx, c, state, projectID, databaseID, subCollectionID, true)
+		return
+	}
+
+	documents, err := backend.FetchSubcollection(ctx, subCollectionID)
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+	if containsField != "" {
+		documents = services.FilterContains(documents, containsField, substring)
+	}
+	if !modifiedSince.IsZero() {
+		documents = services.FilterModifiedSince(documents, modifiedSince)
+	}
+	if sortField != "" {
+		services.OrderDocuments(documents, sortField, sortDir)
+	}
+
+	var processedDocuments []Row
+	decodeErrors := []decodeError{}
+	for _, doc := range documents {
+		decoded, err := services.DecodeFields(doc.Fields)
+		if err != nil {
+			decodeErrors = append(decodeErrors, decodeError{Name: displayName(settings(c), doc.Name), Error: err.Error()})
+			continue
 		}
+		orderNumber := stringAt(decoded, "orderNumber")
+		createdAt := stringAt(decoded, "createdAt")
+		datePosted := stringAt(decoded, "datePosted")
 
 		combinedField := subCollectionID + " - " + orderNumber + " - " + createdAt + " - " + datePosted
-		processedDocuments = append(processedDocuments, map[string]interface{}{
+		processedDocuments = append(processedDocuments, Row{
+			"id":            doc.ID,
 			"name":          doc.Name,
 			"fields":        doc.Fields,
 			"combinedField": combinedField,
 		})
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "Documents fetched successfully",
-		"documents": processedDocuments,
-	})
+	records, shapeErrors, err := shapeRecords(processedDocuments, opts)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	decodeErrors = append(decodeErrors, shapeErrors...)
+
+	body := documentsBody("Documents fetched successfully", records, decodeErrors, opts, projectID, databaseID)
+	state.attach(c, body)
+	respondDocuments(c, body, len(records))
 }
 
 // DeadLettersHandler fetches data from the "dead-letters" collection.
-func DeadLettersHandler(c *gin.Context, projectID, databaseID string) {
-	parentCollection := "dead-letters/NANALL"
+func DeadLettersHandler(c *gin.Context, backend services.FirestoreBackend, projectID, databaseID string) {
 	subCollection := c.Query("subCollection")
 	if subCollection == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "subCollection query parameter is required"})
+		respond(c, http.StatusBadRequest, gin.H{"error": "subCollection query parameter is required"})
 		return
 	}
+	opts, err := parseOutputOptions(c, subCollection)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	containsField, substring, err := containsParam(c, subCollection)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	modifiedSince, err := modifiedSinceParam(c)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := parseStoreOrderPage(c)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, state, ok := firestoreContext(c, "dead-letters")
+	if !ok {
+		return
+	}
+	defer state.done()
 
-	documents, err := services.FetchSpecificDocumentsFromFirestore(projectID, databaseID, parentCollection, subCollection)
+	fetched, err := backend.FetchSubcollection(ctx, subCollection)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondFirestoreError(c, err)
 		return
 	}
+	if containsField != "" {
+		fetched = services.FilterContains(fetched, containsField, substring)
+	}
+	if !modifiedSince.IsZero() {
+		fetched = services.FilterModifiedSince(fetched, modifiedSince)
+	}
 
-	var processedDocuments []map[string]interface{}
-	for _, doc := range documents {
-		fields := doc["fields"].(map[string]interface{})
-		originalPayload := fields["originalPayload"].(map[string]interface{})["mapValue"].(map[string]interface{})["fields"].(map[string]interface{})
-		storeOrders := originalPayload["StoreOrders"].(map[string]interface{})["arrayValue"].(map[string]interface{})["values"].([]interface{})
-
-		for _, storeOrder := range storeOrders {
-			orderFields := storeOrder.(map[string]interface{})["mapValue"].(map[string]interface{})["fields"].(map[string]interface{})
-			combinedField := originalPayload["OrderNumber"].(map[string]interface{})["stringValue"].(string) + " - " +
-				orderFields["BillTo"].(map[string]interface{})["mapValue"].(map[string]interface{})["fields"].(map[string]interface{})["State"].(map[string]interface{})["stringValue"].(string) + " - " +
-				orderFields["BillTo"].(map[string]interface{})["mapValue"].(map[string]interface{})["fields"].(map[string]interface{})["StoreCode"].(map[string]interface{})["stringValue"].(string) + " - " +
-				orderFields["BillTo"].(map[string]interface{})["mapValue"].(map[string]interface{})["fields"].(map[string]interface{})["Suburb"].(map[string]interface{})["stringValue"].(string) + " - " +
-				fields["errorMessage"].(map[string]interface{})["stringValue"].(string)
-
-			processedDocuments = append(processedDocuments, map[string]interface{}{
-				"combinedField": combinedField,
-				"name":          doc["name"],
-				"fields":        fields,
-			})
+	documents := make([]map[string]interface{}, 0, len(fetched))
+	for _, doc := range fetched {
+		documents = append(documents, map[string]interface{}{
+			"id":          doc.ID,
+			"name":        doc.Name,
+			"fields":      doc.Fields,
+			"subCategory": subCollection,
+		})
+	}
+
+	// Expand each dead letter into store-order rows, keeping document order
+	results := make([][]Row, len(documents))
+	orderNumbers := make([]string, len(documents))
+	errs := make([]error, len(documents))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, deadLettersConcurrency(settings(c)))
+	for i, doc := range documents {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, doc map[string]interface{}) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			orderNumbers[i], results[i], errs[i] = processStoreOrders(doc)
+		}(i, doc)
+	}
+	wg.Wait()
+
+	// Malformed dead letters are left out and reported, as shapeRecords does
+	decodeErrors := []decodeError{}
+	for i, err := range errs {
+		if err != nil {
+			decodeErrors = append(decodeErrors, decodeError{Name: displayName(settings(c), fmt.Sprint(documents[i]["name"])), Error: err.Error()})
+		}
+	}
+
+	var processedDocuments []Row
+	var nextCursor string
+	if page.paginate {
+		var keyed []keyedRow
+		for i, rows := range results {
+			for index, row := range rows {
+				key := storeOrderKey{OrderNumber: orderNumbers[i], Index: index, DocumentID: fmt.Sprint(documents[i]["id"])}
+				keyed = append(keyed, keyedRow{key: key, row: row})
+			}
+		}
+		processedDocuments, nextCursor = page.apply(keyed)
+	} else {
+		for _, rows := range results {
+			processedDocuments = append(processedDocuments, rows...)
+		}
+	}
+
+	records, shapeErrors, err := shapeRecords(processedDocuments, opts)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	decodeErrors = append(decodeErrors, shapeErrors...)
+
+	body := documentsBody("Documents fetched successfully", records, decodeErrors, opts, projectID, databaseID)
+	if meta, ok := body["meta"].(gin.H); ok && page.paginate {
+		meta["nextCursor"] = nil
+		if nextCursor != "" {
+			meta["nextCursor"] = nextCursor
+		}
+	}
+	state.attach(c, body)
+	respondDocuments(c, body, len(records))
+}
+
+// DistinctValuesHandler returns the distinct values of a field across the
+// collection named in the path.
+func DistinctValuesHandler(c *gin.Context, backend services.FirestoreBackend) {
+	distinctValues(c, backend, c.Param("name"))
+}
+
+// DistinctQueryHandler returns the distinct values of a field across the
+// collection named by ?collection.
+func DistinctQueryHandler(c *gin.Context, backend services.FirestoreBackend) {
+	collection := c.Query("collection")
+	if collection == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "collection query parameter is required"})
+		return
+	}
+	distinctValues(c, backend, collection)
+}
+
+// distinctValues responds with the sorted distinct values of ?field across a
+// collection. A listing cut short by MAX_PAGES is flagged with the
+// X-Results-Truncated header, since values beyond it are missing.
+func distinctValues(c *gin.Context, backend services.FirestoreBackend, collection string) {
+	field := c.Query("field")
+	if field == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "field query parameter is required"})
+		return
+	}
+
+	ctx, state, ok := firestoreContext(c, collection)
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	documents, truncated, err := backend.FetchCollection(ctx, collection)
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+	if truncated {
+		log.Printf("Warning: distinct values of %s.%s are incomplete, the listing was truncated", collection, field)
+		c.Header("X-Results-Truncated", "true")
+	}
+
+	values := services.DistinctValues(documents, field)
+	state.markHeaders(c)
+	respondDocuments(c, values, len(values))
+}
+
+// CollectionCountHandler counts a collection's documents with a COUNT(*)
+// aggregation. With ?allDescendants=true it counts every collection with that
+// ID at any depth.
+func CollectionCountHandler(c *gin.Context, projectID, databaseID string) {
+	collection := c.Param("name")
+	allDescendants := c.Query("allDescendants") == "true"
+
+	ctx, state, ok := firestoreContext(c, collection)
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	spec := services.QuerySpec{Collection: collection, AllDescendants: allDescendants}
+	count, err := services.CountDocuments(ctx, projectID, databaseID, spec)
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"collection":     collection,
+		"allDescendants": allDescendants,
+		"count":          count,
+	})
+}
+
+// CollectionRangeHandler returns the smallest and largest values of ?field
+// (default the collection's configured time field) in a collection, for
+// time-range and axis hints. Both are null when no document has the field.
+func CollectionRangeHandler(c *gin.Context, projectID, databaseID string) {
+	collection := c.Param("name")
+	field := c.DefaultQuery("field", settings(c).Fields.TimeField(collection, ""))
+	if field == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "field query parameter is required when the collection has no timeField configured"})
+		return
+	}
+	if _, err := services.EscapeFieldPath(field); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// The range is read by ordering on the field
+	if err := services.CheckSortable(c.Request.Context(), collection, field); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	allDescendants := c.Query("allDescendants") == "true"
+
+	ctx, state, ok := firestoreContext(c, collection)
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	spec := services.QuerySpec{Collection: collection, AllDescendants: allDescendants}
+	min, max, found, err := services.FieldRange(ctx, projectID, databaseID, spec, field)
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"collection":     collection,
+		"allDescendants": allDescendants,
+		"field":          field,
+		"min":            min,
+		"max":            max,
+		"empty":          !found,
+	})
+}
+
+// CollectionFreshnessHandler reports the latest timestamp in ?field (default
+// the collection's configured time field, or createdAt) across a collection, read with a one-document query ordered by
+// the field, and its age in seconds for alert thresholds. A collection where
+// no document has the field reports nulls with empty set.
+func CollectionFreshnessHandler(c *gin.Context, projectID, databaseID string) {
+	collection := c.Param("name")
+	field := c.DefaultQuery("field", settings(c).Fields.TimeField(collection, "createdAt"))
+	if _, err := services.EscapeFieldPath(field); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// The latest value is read by ordering on the field
+	if err := services.CheckSortable(c.Request.Context(), collection, field); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	allDescendants := c.Query("allDescendants") == "true"
+
+	ctx, state, ok := firestoreContext(c, collection)
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	spec := services.QuerySpec{Collection: collection, AllDescendants: allDescendants}
+	max, found, err := services.FieldMax(ctx, projectID, databaseID, spec, field)
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+
+	body := gin.H{
+		"collection":     collection,
+		"allDescendants": allDescendants,
+		"field":          field,
+		"lastModified":   nil,
+		"ageSeconds":     nil,
+		"empty":          !found,
+	}
+	if found {
+		lastModified, ok := timestampValue(max)
+		if !ok {
+			respond(c, http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("field %q does not hold timestamps, its latest value is %v", field, max)})
+			return
 		}
+		body["lastModified"] = lastModified.UTC().Format(time.RFC3339Nano)
+		body["ageSeconds"] = time.Since(lastModified).Seconds()
+	}
+	respond(c, http.StatusOK, body)
+}
+
+// timestampValue returns a decoded timestamp, or an RFC3339 string as stored
+// by older documents, as a time.
+func timestampValue(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
 	}
+	return time.Time{}, false
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "Documents fetched successfully",
-		"documents": processedDocuments,
+// AggregateHandler sums or averages a numeric field across ?collection with a
+// Firestore aggregation, optionally restricted by ?where filters. A field
+// without numeric values is reported with 422.
+func AggregateHandler(c *gin.Context, projectID, databaseID string) {
+	collection, op, field := c.Query("collection"), c.Query("op"), c.Query("field")
+	if collection == "" || field == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "collection and field query parameters are required"})
+		return
+	}
+	if op != "sum" && op != "avg" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "op must be sum or avg"})
+		return
+	}
+	where, err := whereParams(c)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	spec := services.QuerySpec{Collection: collection, AllDescendants: c.Query("allDescendants") == "true", Where: where}
+	if _, err := spec.StructuredQuery(c.Request.Context()); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := services.EscapeFieldPath(field); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, state, ok := firestoreContext(c, collection)
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	var value interface{}
+	if op == "sum" {
+		value, err = services.SumField(ctx, projectID, databaseID, spec, field)
+	} else {
+		value, err = services.AverageField(ctx, projectID, databaseID, spec, field)
+	}
+	if errors.Is(err, services.ErrNoNumericValues) {
+		respond(c, http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("field %q has no numeric values in %s", field, collection)})
+		return
+	}
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"collection": collection,
+		"op":         op,
+		"field":      field,
+		"value":      value,
 	})
 }
+
+// SubcollectionsSearchHandler returns the distinct order subcollection (store) IDs
+// for Grafana template variables. Results are cached briefly since they change slowly.
+func SubcollectionsSearchHandler(c *gin.Context, projectID, databaseID string) {
+	parent := c.DefaultQuery("parent", settings(c).Getenv("ORDERS_PARENT"))
+	if parent == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "parent query parameter or ORDERS_PARENT must be set"})
+		return
+	}
+
+	// Each credential profile caches its own listing
+	cacheKey := "subcollections:" + c.Query("profile") + ":" + projectID + "/" + databaseID + "/" + parent
+	if cached, ok := searchCache.Get(cacheKey); ok {
+		ids := cached.([]string)
+		respondDocuments(c, ids, len(ids))
+		return
+	}
+
+	ctx, state, ok := firestoreContext(c, "search")
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	ids, err := services.DistinctSubcollectionIDs(ctx, projectID, databaseID, parent)
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+	// Don't cache a listing cut short by the call budget
+	if !state.budget.Exceeded() {
+		searchCache.Set(cacheKey, ids, searchCacheTTL(settings(c)), 0)
+	}
+
+	state.markHeaders(c)
+	respondDocuments(c, ids, len(ids))
+}
+
+// StructuredQueryHandler runs a structured query described in the request body.
+func StructuredQueryHandler(c *gin.Context, projectID, databaseID string) {
+	// Decode filter values as json.Number so large integers keep every digit
+	var spec services.QuerySpec
+	dec := json.NewDecoder(c.Request.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&spec); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "invalid query: " + err.Error()})
+		return
+	}
+	if value := c.Query("limitToLast"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			respond(c, http.StatusBadRequest, gin.H{"error": "limitToLast must be a positive integer"})
+			return
+		}
+		spec.LimitToLast = n
+	}
+	if _, err := spec.StructuredQuery(c.Request.Context()); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := parseOutputOptions(c, spec.Collection)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	containsField, substring, err := containsParam(c, spec.Collection)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	modifiedSince, err := modifiedSinceParam(c)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, state, ok := firestoreContext(c, spec.Collection)
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	documents, err := services.RunStructuredQuery(ctx, projectID, databaseID, spec)
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+	if containsField != "" {
+		documents = services.FilterContains(documents, containsField, substring)
+	}
+	if !modifiedSince.IsZero() {
+		documents = services.FilterModifiedSince(documents, modifiedSince)
+	}
+
+	records, decodeErrors, err := shapeRecords(documentRows(documents), opts)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body := documentsBody("Documents fetched successfully", records, decodeErrors, opts, projectID, databaseID)
+	state.attach(c, body)
+	respondDocuments(c, body, len(records))
+}
+
+// envelope builds the standard success response, tagged with the project and
+// database that served the request. The records are listed under
+// responseDataKey; empty results serialize as [] rather than null.
+func envelope(rt *config.Runtime, message string, records []Row, projectID, databaseID string) gin.H {
+	if records == nil {
+		records = []Row{}
+	}
+	return gin.H{
+		"message":           message,
+		responseDataKey(rt): records,
+		"meta":              gin.H{"count": len(records)},
+		"project":           projectID,
+		"database":          databaseID,
+	}
+}
+
+// responseDataKey returns the envelope key holding the records:
+// RESPONSE_DATA_KEY, or "documents". LoadRuntime rejects a key that is
+// already one of config.EnvelopeKeys.
+func responseDataKey(rt *config.Runtime) string {
+	if key := rt.Getenv("RESPONSE_DATA_KEY"); key != "" {
+		return key
+	}
+	return "documents"
+}
+
+// sortParams reads the optional sort field and direction from the query
+// string, rejecting fields SORTABLE_FIELDS does not allow for collection.
+func sortParams(c *gin.Context, collection string) (string, string, error) {
+	dir := c.DefaultQuery("dir", "asc")
+	if dir != "asc" && dir != "desc" {
+		return "", "", fmt.Errorf("dir must be asc or desc")
+	}
+	field := c.Query("sort")
+	if field != "" {
+		if err := services.CheckSortable(c.Request.Context(), collection, field); err != nil {
+			return "", "", err
+		}
+	}
+	return field, dir, nil
+}
+
+// containsParam reads the optional contains=field:substring filter, rejecting
+// fields FILTERABLE_FIELDS does not allow for collection.
+func containsParam(c *gin.Context, collection string) (string, string, error) {
+	raw := c.Query("contains")
+	if raw == "" {
+		return "", "", nil
+	}
+	field, substring, found := strings.Cut(raw, ":")
+	if !found || field == "" || substring == "" {
+		return "", "", fmt.Errorf("invalid contains %q, expected field:substring", raw)
+	}
+	if err := services.CheckFilterable(c.Request.Context(), collection, field); err != nil {
+		return "", "", err
+	}
+	return field, substring, nil
+}
+
+// modifiedSinceParam reads the optional ?modifiedSince= (RFC3339) filter, the
+// zero time when it is absent.
+func modifiedSinceParam(c *gin.Context) (time.Time, error) {
+	raw := c.Query("modifiedSince")
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	since, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid modifiedSince %q, expected an RFC3339 time", raw)
+	}
+	return since, nil
+}
+
+// searchCache holds recent /search results. They are never served stale, so
+// entries are dropped as soon as they expire.
+var searchCache = cache.New(cache.DefaultMaxEntries)
+
+// searchCacheTTL returns how long /search results are cached.
+func searchCacheTTL(rt *config.Runtime) time.Duration {
+	ttl, err := time.ParseDuration(rt.Getenv("SEARCH_CACHE_TTL"))
+	if err != nil || ttl <= 0 {
+		return time.Minute
+	}
+	return ttl
+}
+
+// Row is a single processed record returned by a handler.
+type Row map[string]interface{}
+
+// deadLettersConcurrency returns how many dead letters are processed in parallel.
+func deadLettersConcurrency(rt *config.Runtime) int {
+	value, err := strconv.Atoi(rt.Getenv("DEADLETTERS_CONCURRENCY"))
+	if err != nil || value <= 0 {
+		return 1
+	}
+	return value
+}
+
+// processStoreOrders expands a dead-letter document into one row per store
+// order, returning them with the dead letter's order number. Firestore omits
+// fields for a document without any, which is read as no fields. A document
+// whose fields cannot be decoded yields an error instead.
+func processStoreOrders(doc map[string]interface{}) (string, []Row, error) {
+	fields, ok := doc["fields"].(map[string]interface{})
+	if !ok {
+		fields = map[string]interface{}{}
+	}
+	decoded, err := services.DecodeFields(fields)
+	if err != nil {
+		return "", nil, err
+	}
+	storeOrders, _ := valueAt(decoded, "originalPayload.StoreOrders").([]interface{})
+	orderNumber := stringAt(decoded, "originalPayload.OrderNumber")
+
+	var rows []Row
+	for _, storeOrder := range storeOrders {
+		order, _ := storeOrder.(map[string]interface{})
+		combinedField := orderNumber + " - " +
+			stringAt(order, "BillTo.State") + " - " +
+			stringAt(order, "BillTo.StoreCode") + " - " +
+			stringAt(order, "BillTo.Suburb") + " - " +
+			stringAt(decoded, "errorMessage")
+
+		rows = append(rows, Row{
+			"combinedField": combinedField,
+			"id":            doc["id"],
+			"name":          doc["name"],
+			"fields":        fields,
+		})
+	}
+	return orderNumber, rows, nil
+}
+
+// valueAt returns the decoded value at a dot-notation path, or nil when the
+// path is missing or holds a Firestore nullValue.
+func valueAt(decoded map[string]interface{}, path string) interface{} {
+	value, _ := services.LookupPath(decoded, path)
+	return value
+}
+
+// stringAt returns the decoded value at a dot-notation path as a string.
+// Missing and null values yield an empty string.
+func stringAt(decoded map[string]interface{}, path string) string {
+	return valueText(valueAt(decoded, path))
+}
+
+// valueText renders a decoded value as a string, nil as an empty one.
+func valueText(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(value)
+	}
+}
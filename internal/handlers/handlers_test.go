@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"crossfire-grafana/internal/config"
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// testRequest returns a gin context for a request served with a Runtime
+// holding env and fields, and the recorder its response is written to.
+func testRequest(method, target string, body io.Reader, env map[string]string, fields *config.FieldConfig) (*gin.Context, *httptest.ResponseRecorder) {
+	if fields == nil {
+		fields = &config.FieldConfig{Collections: map[string]config.CollectionConfig{}}
+	}
+	rt := &config.Runtime{Fields: fields, Env: env}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, body)
+	c.Request = c.Request.WithContext(config.WithRuntime(c.Request.Context(), rt))
+	if body != nil {
+		c.Request.Header.Set("Content-Type", "application/json")
+	}
+	return c, w
+}
+
+// fakeFirestore points Firestore calls at a test server running handler for
+// the duration of a test, the way FIRESTORE_EMULATOR_HOST does.
+func fakeFirestore(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	services.Configure(config.Config{EmulatorHost: strings.TrimPrefix(server.URL, "http://")})
+	t.Cleanup(func() {
+		services.Configure(config.Config{})
+		server.Close()
+	})
+}
+
+// fakeBackend serves fixed collections and collection groups.
+type fakeBackend struct {
+	collections    map[string][]services.FirestoreDocument
+	subcollections map[string][]services.FirestoreDocument
+}
+
+func (b fakeBackend) FetchCollection(ctx context.Context, collection string) ([]services.FirestoreDocument, bool, error) {
+	return b.collections[collection], false, nil
+}
+
+func (b fakeBackend) FetchSubcollection(ctx context.Context, subCollection string) ([]services.FirestoreDocument, error) {
+	return b.subcollections[subCollection], nil
+}
+
+func TestProcessStoreOrdersWithoutFields(t *testing.T) {
+	for _, doc := range []map[string]interface{}{
+		{"id": "a", "name": "dead-letters/a"},
+		{"id": "b", "name": "dead-letters/b", "fields": nil},
+	} {
+		orderNumber, rows, err := processStoreOrders(doc)
+		if err != nil || orderNumber != "" || len(rows) != 0 {
+			t.Errorf("processStoreOrders(%v) = %q, %v, %v; want no rows", doc["id"], orderNumber, rows, err)
+		}
+	}
+}
+
+func TestProcessStoreOrdersReportsDecodeErrors(t *testing.T) {
+	doc := map[string]interface{}{
+		"id":     "a",
+		"name":   "dead-letters/a",
+		"fields": map[string]interface{}{"errorMessage": map[string]interface{}{"integerValue": "not a number"}},
+	}
+	if _, rows, err := processStoreOrders(doc); err == nil {
+		t.Errorf("processStoreOrders = %v, nil; want a decode error", rows)
+	}
+}
+
+func TestEnvelopeKeysAreReserved(t *testing.T) {
+	rt := &config.Runtime{Env: map[string]string{"RESPONSE_DATA_KEY": "rows"}}
+	opts := outputOptions{format: "rows", rt: rt}
+	body := documentsBody("ok", []Row{{"id": "a"}}, []decodeError{{Name: "b", Error: "bad"}}, opts, "p", "d")
+	withFirestoreError(body, &services.APIError{Status: "NOT_FOUND", Message: "missing"})
+	if _, ok := body["rows"]; !ok {
+		t.Fatalf("body = %v, want the records under rows", body)
+	}
+
+	opts.format = "columns"
+	columns := documentsBody("ok", []Row{{"id": "a"}}, nil, opts, "p", "d")
+	for _, b := range []gin.H{body, columns} {
+		for key := range b {
+			if key != "rows" && !config.EnvelopeKeys[key] {
+				t.Errorf("envelope key %q is missing from config.EnvelopeKeys", key)
+			}
+		}
+	}
+}
+
+func TestModifiedSinceParam(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", time.Time{}, false},
+		{"2024-12-16T10:00:00Z", time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC), false},
+		{"2024-12-16T21:00:00.5+11:00", time.Date(2024, 12, 16, 10, 0, 0, 5e8, time.UTC), false},
+		{"2024-12-16", time.Time{}, true},
+		{"yesterday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		c := queryContext(url.Values{"modifiedSince": {tt.raw}})
+		got, err := modifiedSinceParam(c)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("modifiedSinceParam(%q) = %v, %v; want %v, error %v", tt.raw, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// timeFields configures orders with datePosted as their time field and leaves
+// restaurants unconfigured.
+var timeFields = &config.FieldConfig{Collections: map[string]config.CollectionConfig{
+	"orders": {TimeField: "datePosted"},
+}}
+
+func TestCollectionTimeFieldQueries(t *testing.T) {
+	var ordered []string
+	fakeFirestore(t, func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			StructuredQuery struct {
+				OrderBy []struct {
+					Field struct {
+						FieldPath string `json:"fieldPath"`
+					} `json:"field"`
+				} `json:"orderBy"`
+			} `json:"structuredQuery"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		for _, order := range payload.StructuredQuery.OrderBy {
+			ordered = append(ordered, order.Field.FieldPath)
+		}
+		w.Write([]byte(`[]`))
+	})
+
+	tests := []struct {
+		endpoint, collection, query string
+		wantCode                    int
+		wantField                   string
+	}{
+		{"freshness", "orders", "", http.StatusOK, "datePosted"},
+		{"freshness", "restaurants", "", http.StatusOK, "createdAt"},
+		{"freshness", "orders", "field=updatedAt", http.StatusOK, "updatedAt"},
+		{"range", "orders", "", http.StatusOK, "datePosted"},
+		{"range", "restaurants", "", http.StatusBadRequest, ""},
+		{"range", "restaurants", "field=openedAt", http.StatusOK, "openedAt"},
+	}
+	for _, tt := range tests {
+		ordered = nil
+		c, w := testRequest("GET", "/collections/"+tt.collection+"/"+tt.endpoint+"?"+tt.query, nil, nil, timeFields)
+		c.Params = gin.Params{{Key: "name", Value: tt.collection}}
+		if tt.endpoint == "range" {
+			CollectionRangeHandler(c, "p", "d")
+		} else {
+			CollectionFreshnessHandler(c, "p", "d")
+		}
+		if w.Code != tt.wantCode {
+			t.Errorf("%s of %s?%s: status = %d, want %d: %s", tt.endpoint, tt.collection, tt.query, w.Code, tt.wantCode, w.Body.String())
+			continue
+		}
+		for _, field := range ordered {
+			if field != tt.wantField {
+				t.Errorf("%s of %s?%s: ordered by %s, want %s", tt.endpoint, tt.collection, tt.query, field, tt.wantField)
+			}
+		}
+		if tt.wantField != "" && len(ordered) == 0 {
+			t.Errorf("%s of %s?%s: no query ordered by %s", tt.endpoint, tt.collection, tt.query, tt.wantField)
+		}
+	}
+}
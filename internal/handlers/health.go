@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"crossfire-grafana/internal/config"
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultHealthTimeout bounds the health check's Firestore call.
+const defaultHealthTimeout = 3 * time.Second
+
+// healthTimeout returns HEALTH_TIMEOUT, or 3s when unset or invalid.
+func healthTimeout(rt *config.Runtime) time.Duration {
+	if value := rt.Getenv("HEALTH_TIMEOUT"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Invalid HEALTH_TIMEOUT %q, using %s", value, defaultHealthTimeout)
+	}
+	return defaultHealthTimeout
+}
+
+// HealthzHandler reports whether Firestore is reachable. The check is bounded
+// by HEALTH_TIMEOUT so a slow Firestore fails the probe with 503 instead of
+// hanging it.
+func HealthzHandler(c *gin.Context, projectID, databaseID string) {
+	timeout := healthTimeout(settings(c))
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	started := time.Now()
+	if err := services.Ping(ctx, projectID, databaseID); err != nil {
+		message := err.Error()
+		if ctx.Err() == context.DeadlineExceeded {
+			message = "firestore did not respond within " + timeout.String()
+		}
+		respond(c, http.StatusServiceUnavailable, gin.H{"status": "unavailable", "firestore": message})
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"status":    "ok",
+		"firestore": "ok",
+		"latencyMs": time.Since(started).Milliseconds(),
+	})
+}
+
+// LivezHandler reports that the process is up, without checking dependencies.
+func LivezHandler(c *gin.Context) {
+	respond(c, http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ReadyHandler reports whether the server should receive traffic: the initial
+// cache warm has completed and an access token can be obtained. It returns
+// 503 until both hold.
+func ReadyHandler(c *gin.Context, warmer *services.CacheWarmer) {
+	if !warmer.Ready() {
+		respond(c, http.StatusServiceUnavailable, gin.H{"status": "not ready", "reason": "cache warm in progress"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthTimeout(settings(c)))
+	defer cancel()
+	if _, err := services.GetFirestoreAccessToken(ctx); err != nil {
+		respond(c, http.StatusServiceUnavailable, gin.H{"status": "not ready", "reason": err.Error()})
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"status": "ready"})
+}
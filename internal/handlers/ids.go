@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// idsOnlyParam reports whether ?idsOnly=true asks for document IDs instead of
+// documents. sort, contains and modifiedSince need document data, and where
+// filters need a query, so they cannot be combined with it.
+func idsOnlyParam(c *gin.Context) (bool, error) {
+	if c.Query("idsOnly") != "true" {
+		return false, nil
+	}
+	if c.Query("sort") != "" || c.Query("contains") != "" || c.Query("modifiedSince") != "" || c.Query("where") != "" {
+		return false, fmt.Errorf("idsOnly cannot be combined with sort, contains, modifiedSince or where")
+	}
+	return true, nil
+}
+
+// respondDocumentIDs writes the IDs of the documents in collection (or with
+// allDescendants, every collection with that ID) as a bare array, with
+// X-Results-Truncated: true when MAX_PAGES cut the listing short.
+func respondDocumentIDs(ctx context.Context, c *gin.Context, state *requestState, projectID, databaseID, collection string, allDescendants bool) {
+	ids, truncated, err := services.ListDocumentIDs(ctx, projectID, databaseID, collection, allDescendants)
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+	if truncated {
+		c.Header("X-Results-Truncated", "true")
+	}
+	state.markHeaders(c)
+	respondDocuments(c, ids, len(ids))
+}
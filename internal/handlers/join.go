@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// JoinHandler left-joins two collections on a field (the document ID by
+// default) and returns the left documents with the matching right document's
+// fields nested under the right collection's name.
+func JoinHandler(c *gin.Context, backend services.FirestoreBackend, projectID, databaseID string) {
+	left, right := c.Query("left"), c.Query("right")
+	if left == "" || right == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "left and right query parameters are required"})
+		return
+	}
+	on := c.DefaultQuery("on", "id")
+
+	sortField, sortDir, err := sortParams(c, left)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := parseOutputOptions(c, left)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, state, ok := firestoreContext(c, "join")
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	leftDocuments, leftTruncated, err := backend.FetchCollection(ctx, left)
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+	rightDocuments, rightTruncated, err := backend.FetchCollection(ctx, right)
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+
+	documents := services.JoinDocuments(leftDocuments, rightDocuments, on, right)
+	if sortField != "" {
+		services.OrderDocuments(documents, sortField, sortDir)
+	}
+
+	records, decodeErrors, err := shapeRecords(documentRows(documents), opts)
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body := documentsBody("Documents joined successfully from "+left+" and "+right, records, decodeErrors, opts, projectID, databaseID)
+	body["truncated"] = leftTruncated || rightTruncated
+	state.attach(c, body)
+	respondDocuments(c, body, len(records))
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"crossfire-grafana/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// metricsHandler serves the registered metrics.
+var metricsHandler = metrics.Handler()
+
+// MetricsHandler exposes the registered metrics in the Prometheus text format,
+// or in OpenMetrics when the Accept header asks for application/openmetrics-text.
+func MetricsHandler(c *gin.Context) {
+	metricsHandler.ServeHTTP(c.Writer, c.Request)
+}
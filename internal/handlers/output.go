@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"crossfire-grafana/internal/config"
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// outputOptions controls how record fields are shaped before serialization.
+type outputOptions struct {
+	format      string
+	decode      bool
+	path        bool
+	typeHints   map[string]string
+	flatten     bool
+	flattenOpts services.FlattenOptions
+	aliases     map[string]string
+	strictAlias bool
+	derive      []derivedField
+	renames     map[string]string
+	canonical   map[string]string
+	// rt is the configuration the request was served with
+	rt *config.Runtime
+}
+
+// parseOutputOptions reads the output shaping query parameters, together with
+// the derived fields configured for collection.
+func parseOutputOptions(c *gin.Context, collection string) (outputOptions, error) {
+	opts := outputOptions{
+		format:  c.DefaultQuery("format", "rows"),
+		decode:  c.Query("decode") == "true" || c.Query("coerce") != "" || c.Query("path") == "true",
+		path:    c.Query("path") == "true",
+		flatten: c.Query("flatten") == "true",
+		flattenOpts: services.FlattenOptions{
+			CollapseSingletonArrays: c.Query("collapseSingletonArrays") == "true",
+		},
+		strictAlias: c.Query("aliasStrict") == "true",
+		rt:          settings(c),
+	}
+	switch {
+	case opts.format == "rows":
+	case opts.format == "columns":
+		// Columns are built from flattened rows
+		opts.flatten = true
+	case formatters[opts.format] == nil:
+		return opts, fmt.Errorf("unsupported format %q", opts.format)
+	}
+	if formatters[responseFormat(c)].Flattens() {
+		opts.flatten = true
+	}
+	if c.Query("partitionBy") != "" {
+		if responseFormat(c) != "ndjson" {
+			return opts, fmt.Errorf("partitionBy requires format=ndjson")
+		}
+		// Records are partitioned by their decoded values
+		opts.decode = true
+	}
+
+	if raw := c.Query("alias"); raw != "" {
+		opts.aliases = make(map[string]string)
+		displays := make(map[string]string)
+		for _, pair := range strings.Split(raw, ",") {
+			source, display, found := strings.Cut(pair, ":")
+			source, display = strings.TrimSpace(source), strings.TrimSpace(display)
+			if !found || source == "" || display == "" {
+				return opts, fmt.Errorf("invalid alias %q, expected source:display", pair)
+			}
+			if other, ok := displays[display]; ok && other != source {
+				return opts, fmt.Errorf("alias %q is used for both %s and %s", display, other, source)
+			}
+			displays[display] = source
+			opts.aliases[source] = display
+		}
+	}
+
+	derive, err := parseDerivedFields(opts.rt, collection, c.QueryArray("derive"))
+	if err != nil {
+		return opts, err
+	}
+	opts.derive = derive
+	opts.renames = opts.rt.Fields.Renames(collection)
+	opts.canonical = opts.rt.Fields.CanonicalNames()
+
+	// Per-request coerce hints override the configured FIELD_TYPE_HINTS
+	requested, err := config.ParseTypeHints(c.Query("coerce"))
+	if err != nil {
+		return opts, err
+	}
+	hints := make(map[string]string, len(opts.rt.TypeHints)+len(requested))
+	for path, kind := range opts.rt.TypeHints {
+		hints[path] = kind
+	}
+	for path, kind := range requested {
+		hints[path] = kind
+	}
+	if len(hints) > 0 {
+		opts.typeHints = hints
+	}
+
+	return opts, nil
+}
+
+// documentRows converts fetched documents into response records.
+func documentRows(docs []services.FirestoreDocument) []Row {
+	rows := make([]Row, 0, len(docs))
+	for _, doc := range docs {
+		rows = append(rows, Row{
+			"id":     doc.ID,
+			"name":   doc.Name,
+			"fields": doc.Fields,
+		})
+	}
+	return rows
+}
+
+// shapeRecords applies the output options to each record's Firestore fields.
+// Decoded fields first have alternative names replaced by their canonical
+// names, and derived fields are rendered from them. Decoding applies the
+// type hints and the collection's configured key renames and, with path,
+// adds the document name's components as _path unless the document has a
+// _path field of its own; with flatten or aliases the decoded fields are also
+// flattened to dotted keys, then any aliased keys are renamed.
+// Records whose fields cannot be decoded are left out and reported as decode
+// errors, so a few malformed documents don't fail the whole response. Record
+// names are finally shortened to their displayName.
+func shapeRecords(records []Row, opts outputOptions) ([]Row, []decodeError, error) {
+	flatten := opts.flatten || len(opts.aliases) > 0
+	if !opts.decode && !flatten && len(opts.derive) == 0 {
+		trimDocumentNames(opts.rt, records)
+		return records, nil, nil
+	}
+
+	// Coercion failures repeat per document; sample them and log the total once
+	sampler := services.NewLogSampler(opts.rt)
+	defer sampler.Summary("Values that could not be coerced")
+
+	matched := make(map[string]bool)
+	shaped := make([]Row, 0, len(records))
+	decodeErrors := []decodeError{}
+	for _, record := range records {
+		fields, _ := record["fields"].(map[string]interface{})
+		decoded, err := services.DecodeFields(fields)
+		if err != nil {
+			decodeErrors = append(decodeErrors, decodeError{Name: displayName(opts.rt, fmt.Sprint(record["name"])), Error: err.Error()})
+			continue
+		}
+		shaped = append(shaped, record)
+		decoded = services.CanonicalizeKeys(decoded, opts.canonical)
+		services.ApplyTypeHints(decoded, opts.typeHints, sampler)
+		deriveFields(record, decoded, opts.derive)
+		if !opts.decode && !flatten {
+			continue
+		}
+		decoded = services.RenameKeys(decoded, opts.renames)
+		if _, exists := decoded["_path"]; opts.path && !exists {
+			if path := documentPath(fmt.Sprint(record["name"])); path != nil {
+				decoded["_path"] = path
+			}
+		}
+		if opts.rt.Getenv("LARGE_INTS_AS_STRINGS") == "true" {
+			services.StringifyLargeInts(decoded)
+		}
+		if !flatten {
+			record["fields"] = decoded
+			continue
+		}
+
+		flat, err := services.Flatten(decoded, opts.flattenOpts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to flatten %v: %v", record["name"], err)
+		}
+
+		if len(opts.aliases) > 0 {
+			if flat, err = aliasKeys(flat, opts.aliases, matched); err != nil {
+				return nil, nil, err
+			}
+		}
+		record["fields"] = flat
+	}
+
+	if opts.strictAlias {
+		for source := range opts.aliases {
+			if !matched[source] {
+				return nil, nil, fmt.Errorf("alias source %q matched no field", source)
+			}
+		}
+	}
+	trimDocumentNames(opts.rt, shaped)
+	return shaped, decodeErrors, nil
+}
+
+// aliasKeys returns flat with each aliased key renamed to its display name,
+// recording the matched aliases. Renames are read from flat as it was, so
+// chained aliases (a:b,b:c) give the same result in any order. A display name
+// that would replace a field kept under its own name is an error.
+func aliasKeys(flat map[string]interface{}, aliases map[string]string, matched map[string]bool) (map[string]interface{}, error) {
+	aliased := make(map[string]interface{}, len(flat))
+	sources := make(map[string]string)
+	for key, value := range flat {
+		if display, ok := aliases[key]; ok {
+			matched[key] = true
+			aliased[display] = value
+			sources[display] = key
+		}
+	}
+	for key, value := range flat {
+		if _, ok := aliases[key]; ok {
+			continue
+		}
+		if source, ok := sources[key]; ok {
+			return nil, fmt.Errorf("alias %s:%s collides with the field %s", source, key, key)
+		}
+		aliased[key] = value
+	}
+	return aliased, nil
+}
+
+// decodeError reports a document whose fields could not be decoded.
+type decodeError struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// documentsBody builds the enveloped response for records: an array of rows
+// under responseDataKey, or for format=columns a "fields" array of columns.
+// Any decode errors are listed under decodeErrors. Other formats of the rows
+// are rendered by respond.
+func documentsBody(message string, records []Row, decodeErrors []decodeError, opts outputOptions, projectID, databaseID string) gin.H {
+	body := envelope(opts.rt, message, records, projectID, databaseID)
+	if len(decodeErrors) > 0 {
+		body["decodeErrors"] = decodeErrors
+	}
+	if opts.format == "columns" {
+		delete(body, responseDataKey(opts.rt))
+		body["fields"] = toColumns(records)
+	}
+	return body
+}
+
+// flatRow merges a record's top-level keys with its flattened fields.
+func flatRow(record Row) map[string]interface{} {
+	row := make(map[string]interface{})
+	for key, value := range record {
+		if key != "fields" {
+			row[key] = value
+		}
+	}
+	if fields, ok := record["fields"].(map[string]interface{}); ok {
+		for key, value := range fields {
+			row[key] = value
+		}
+	}
+	return row
+}
+
+// columnKeys returns the union of keys across rows: id and name first, then
+// the rest alphabetically.
+func columnKeys(rows []map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var rest []string
+	for _, row := range rows {
+		for key := range row {
+			if !seen[key] {
+				seen[key] = true
+				if key != "id" && key != "name" {
+					rest = append(rest, key)
+				}
+			}
+		}
+	}
+	sort.Strings(rest)
+
+	var keys []string
+	for _, key := range []string{"id", "name"} {
+		if seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	return append(keys, rest...)
+}
+
+// toColumns transposes records into Grafana data-frame style columns. Every
+// column has one value per record, with null where a record lacks the key.
+func toColumns(records []Row) []gin.H {
+	rows := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		rows = append(rows, flatRow(record))
+	}
+
+	columns := []gin.H{}
+	for _, key := range columnKeys(rows) {
+		values := make([]interface{}, len(rows))
+		for i, row := range rows {
+			values[i] = row[key]
+		}
+		kind := columnType(values)
+		if kind == "time" {
+			for i, value := range values {
+				if t, ok := value.(time.Time); ok {
+					values[i] = t.UnixMilli()
+				}
+			}
+		}
+		columns = append(columns, gin.H{"name": key, "type": kind, "values": values})
+	}
+	return columns
+}
+
+// toGrafanaTable converts records into the table response of Grafana's JSON
+// datasources: {"columns": [{"text", "type"}], "rows": [[...]], "type": "table"}.
+// Columns are the union of the flattened keys, ordered as by format=columns,
+// and cells a record lacks are null. Times are epoch milliseconds; columns
+// that are not numbers, strings or times carry no type.
+func toGrafanaTable(records []Row) gin.H {
+	columns := toColumns(records)
+	tableColumns := make([]gin.H, len(columns))
+	for i, column := range columns {
+		tableColumns[i] = gin.H{"text": column["name"]}
+		switch kind := column["type"]; kind {
+		case "number", "string", "time":
+			tableColumns[i]["type"] = kind
+		}
+	}
+
+	rows := make([][]interface{}, len(records))
+	for i := range rows {
+		rows[i] = make([]interface{}, len(columns))
+		for j, column := range columns {
+			rows[i][j] = column["values"].([]interface{})[i]
+		}
+	}
+	return gin.H{"columns": tableColumns, "rows": rows, "type": "table"}
+}
+
+// columnType infers a Grafana field type from a column's non-null values.
+func columnType(values []interface{}) string {
+	kind := ""
+	for _, value := range values {
+		var current string
+		switch value.(type) {
+		case nil:
+			continue
+		case int64, float64, int:
+			current = "number"
+		case bool:
+			current = "boolean"
+		case time.Time:
+			current = "time"
+		case string:
+			current = "string"
+		default:
+			current = "other"
+		}
+		if kind == "" {
+			kind = current
+		} else if kind != current {
+			return "other"
+		}
+	}
+	if kind == "" {
+		return "string"
+	}
+	return kind
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"crossfire-grafana/internal/config"
+)
+
+func TestAliasKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		aliases map[string]string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:    "rename",
+			aliases: map[string]string{"a": "x"},
+			want:    map[string]interface{}{"x": 1, "b": 2, "c": 3},
+		},
+		{
+			name:    "chained",
+			aliases: map[string]string{"a": "b", "b": "c", "c": "d"},
+			want:    map[string]interface{}{"b": 1, "c": 2, "d": 3},
+		},
+		{
+			name:    "swap",
+			aliases: map[string]string{"a": "b", "b": "a"},
+			want:    map[string]interface{}{"a": 2, "b": 1, "c": 3},
+		},
+		{
+			name:    "unmatched source",
+			aliases: map[string]string{"missing": "x"},
+			want:    map[string]interface{}{"a": 1, "b": 2, "c": 3},
+		},
+		{
+			name:    "collision with a kept field",
+			aliases: map[string]string{"a": "c"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		// Apply each case repeatedly, since map order varies between runs
+		for i := 0; i < 20; i++ {
+			flat := map[string]interface{}{"a": 1, "b": 2, "c": 3}
+			got, err := aliasKeys(flat, tt.aliases, map[string]bool{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s: aliasKeys error = %v, want error %v", tt.name, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("%s: aliasKeys = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestShapeRecordsPath(t *testing.T) {
+	const name = "projects/p/databases/d/documents/dead-letters/NANALL/2024-12-16/xyz"
+	tests := []struct {
+		name   string
+		path   bool
+		fields map[string]interface{}
+		want   interface{}
+	}{
+		{"without path", false, map[string]interface{}{}, nil},
+		{"with path", true, map[string]interface{}{}, "2024-12-16"},
+		{"own _path field kept", true, map[string]interface{}{"_path": map[string]interface{}{"stringValue": "mine"}}, "mine"},
+	}
+	for _, tt := range tests {
+		opts := outputOptions{decode: true, path: tt.path, rt: &config.Runtime{}}
+		records, _, err := shapeRecords([]Row{{"id": "xyz", "name": name, "fields": tt.fields}}, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		fields := records[0]["fields"].(map[string]interface{})
+		var got interface{}
+		switch path := fields["_path"].(type) {
+		case map[string]interface{}:
+			got = path["date"]
+		case string:
+			got = path
+		}
+		if got != tt.want {
+			t.Errorf("%s: _path = %v, want %v", tt.name, fields["_path"], tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"crossfire-grafana/internal/config"
+)
+
+// documentPath splits a full document name
+// (projects/p/databases/d/documents/dead-letters/NANALL/2024-12-16/xyz) into
+// the components returned as _path in decoded output: the root collection,
+// the parent document ID and the document's own collection for nested
+// documents, that collection again as date when it is a YYYY-MM-DD date
+// subcollection, the document ID, and every path segment. Names that are not
+// document names yield nil.
+func documentPath(name string) map[string]interface{} {
+	_, relative, found := strings.Cut(name, "/documents/")
+	if !found {
+		return nil
+	}
+	segments := strings.Split(relative, "/")
+	if len(segments) < 2 || len(segments)%2 != 0 {
+		return nil
+	}
+	// Decoded values hold arrays as []interface{}
+	items := make([]interface{}, len(segments))
+	for i, segment := range segments {
+		items[i] = segment
+	}
+
+	path := map[string]interface{}{
+		"collection": segments[0],
+		"docId":      segments[len(segments)-1],
+		"segments":   items,
+	}
+	if len(segments) > 2 {
+		subcollection := segments[len(segments)-2]
+		path["parentId"] = segments[len(segments)-3]
+		path["subcollection"] = subcollection
+		if _, err := time.Parse(deadLetterDateLayout, subcollection); err == nil {
+			path["date"] = subcollection
+		}
+	}
+	return path
+}
+
+// displayName returns the document name shown in responses: the path relative
+// to the documents root (dead-letters/NANALL/2024-12-16/xyz), or the full name
+// when FULL_DOCUMENT_NAMES=true. Names that are not document names are
+// returned as they are.
+func displayName(rt *config.Runtime, name string) string {
+	if rt.Getenv("FULL_DOCUMENT_NAMES") == "true" {
+		return name
+	}
+	if _, relative, found := strings.Cut(name, "/documents/"); found {
+		return relative
+	}
+	return name
+}
+
+// trimDocumentNames replaces the name of each record with its displayName.
+func trimDocumentNames(rt *config.Runtime, records []Row) {
+	for _, record := range records {
+		if name, ok := record["name"].(string); ok {
+			record["name"] = displayName(rt, name)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"crossfire-grafana/internal/config"
+	"crossfire-grafana/internal/services"
+)
+
+func TestDisplayName(t *testing.T) {
+	const full = "projects/p/databases/(default)/documents/dead-letters/NANALL/2024-12-16/xyz"
+	tests := []struct {
+		fullNames string
+		name      string
+		want      string
+	}{
+		{"", full, "dead-letters/NANALL/2024-12-16/xyz"},
+		{"false", "projects/p/databases/d/documents/restaurants/a", "restaurants/a"},
+		{"true", full, full},
+		{"", "restaurants/a", "restaurants/a"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		rt := &config.Runtime{Env: map[string]string{"FULL_DOCUMENT_NAMES": tt.fullNames}}
+		if got := displayName(rt, tt.name); got != tt.want {
+			t.Errorf("FULL_DOCUMENT_NAMES=%q: displayName(%q) = %q, want %q", tt.fullNames, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResponsesTrimDocumentNames(t *testing.T) {
+	backend := fakeBackend{collections: map[string][]services.FirestoreDocument{
+		"restaurants": {{
+			ID:     "a",
+			Name:   "projects/p/databases/d/documents/restaurants/a",
+			Fields: map[string]interface{}{"title": map[string]interface{}{"stringValue": "Thai Palace"}},
+		}},
+	}}
+
+	tests := []struct {
+		fullNames, format, want string
+	}{
+		{"", "json", "restaurants/a"},
+		{"true", "json", "projects/p/databases/d/documents/restaurants/a"},
+		{"", "csv", "restaurants/a"},
+		{"true", "csv", "projects/p/databases/d/documents/restaurants/a"},
+	}
+	for _, tt := range tests {
+		c, w := testRequest("GET", "/restaurants-cache?format="+tt.format, nil, map[string]string{"FULL_DOCUMENT_NAMES": tt.fullNames}, nil)
+		RestaurantsCacheHandler(c, backend, "p", "d")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", tt.format, w.Code, w.Body.String())
+		}
+
+		var got string
+		if tt.format == "csv" {
+			// The name column follows id
+			lines := strings.Split(w.Body.String(), "\n")
+			got = strings.Split(lines[1], ",")[1]
+		} else {
+			var body struct{ Documents []struct{ Name string } }
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if len(body.Documents) == 1 {
+				got = body.Documents[0].Name
+			}
+		}
+		if got != tt.want {
+			t.Errorf("FULL_DOCUMENT_NAMES=%q, %s: name = %q, want %q", tt.fullNames, tt.format, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// respond writes obj with the formatter selected by responseFormat; error
+// responses are always JSON.
+func respond(c *gin.Context, code int, obj interface{}) {
+	c.Writer.Header().Add("Vary", "Accept")
+	if code >= http.StatusMultipleChoices {
+		writeJSON(c, code, obj)
+		return
+	}
+	formatters[responseFormat(c)].Format(c, code, obj)
+}
+
+// writeJSON writes obj as JSON. Output is indented with ?pretty=true, or by
+// default when PRETTY_JSON=true or APP_ENV=development; otherwise it is compact.
+// With ?callback=fn a successful response to a GET request is wrapped as
+// JSONP; a callback that is not a plain (dotted) JavaScript identifier is
+// rejected with 400. Errors and other methods, which a script tag cannot
+// load, stay JSON.
+func writeJSON(c *gin.Context, code int, obj interface{}) {
+	if callback := c.Query("callback"); callback != "" && jsonpAllowed(c, code) {
+		if len(callback) > maxCallbackLength || !callbackName.MatchString(callback) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "callback must be a JavaScript identifier such as handleData or app.handleData"})
+			return
+		}
+		c.Render(code, jsonpBody{Callback: callback, Data: obj, Indent: prettyRequested(c)})
+		return
+	}
+	if prettyRequested(c) {
+		c.Render(code, indentedJSON{Data: obj})
+		return
+	}
+	c.JSON(code, obj)
+}
+
+// jsonpAllowed reports whether a response may be wrapped as JSONP: only 2xx
+// responses to GET requests are.
+func jsonpAllowed(c *gin.Context, code int) bool {
+	return c.Request.Method == http.MethodGet && code >= http.StatusOK && code < http.StatusMultipleChoices
+}
+
+// maxCallbackLength bounds the length of a JSONP callback name.
+const maxCallbackLength = 128
+
+// callbackName matches the JSONP callback names accepted: identifiers,
+// optionally dotted, so the callback cannot inject script.
+var callbackName = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// jsonpBody renders Data as a JSONP callback invocation.
+type jsonpBody struct {
+	Callback string
+	Data     interface{}
+	Indent   bool
+}
+
+// Render writes callback(json); prefixed with an empty comment, which stops
+// the body from being sniffed as another content type.
+func (r jsonpBody) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	var encoded []byte
+	var err error
+	if r.Indent {
+		encoded, err = json.MarshalIndent(r.Data, "", "  ")
+	} else {
+		encoded, err = json.Marshal(r.Data)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "/**/%s(%s);", r.Callback, encoded)
+	return err
+}
+
+// WriteContentType sets the JavaScript content type.
+func (r jsonpBody) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+}
+
+// respondDocuments writes a successful document listing holding count items,
+// or 204 No Content when it is empty and EMPTY_AS_204=true.
+func respondDocuments(c *gin.Context, body interface{}, count int) {
+	if count == 0 && settings(c).Getenv("EMPTY_AS_204") == "true" {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	respond(c, http.StatusOK, body)
+}
+
+// prettyRequested reports whether the response should be indented.
+func prettyRequested(c *gin.Context) bool {
+	if pretty := c.Query("pretty"); pretty != "" {
+		return pretty == "true"
+	}
+	return settings(c).Getenv("PRETTY_JSON") == "true" || settings(c).Getenv("APP_ENV") == "development"
+}
+
+// indentedJSON renders JSON indented with two spaces.
+type indentedJSON struct {
+	Data interface{}
+}
+
+// Render writes the indented JSON body.
+func (r indentedJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	encoded, err := json.MarshalIndent(r.Data, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(encoded)
+	return err
+}
+
+// WriteContentType sets the JSON content type.
+func (r indentedJSON) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+}
+
+// statusClientClosedRequest is the non-standard status logged for requests
+// whose client disconnected before the response was written.
+const statusClientClosedRequest = 499
+
+// respondFirestoreError writes the response for a failed Firestore call: 404
+// naming the database when it does not exist, 429 with code
+// FIRESTORE_QUOTA_EXCEEDED when the quota is exhausted, 503 with code
+// FIRESTORE_CALL_BUDGET_EXCEEDED when the request's call budget ran out, 504
+// when the read (or the access token fetch before it) timed out, 500 otherwise.
+func respondFirestoreError(c *gin.Context, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		respond(c, http.StatusGatewayTimeout, gin.H{"error": "Firestore read timed out: " + err.Error()})
+		return
+	}
+	// The client has gone away; the status is only seen in the logs
+	if errors.Is(err, context.Canceled) && c.Request.Context().Err() != nil {
+		respond(c, statusClientClosedRequest, gin.H{"error": "client closed the request: " + err.Error()})
+		return
+	}
+
+	// Listings return what they read before the budget ran out; this is a
+	// call that could not read anything
+	if errors.Is(err, services.ErrBudgetExceeded) {
+		respond(c, http.StatusServiceUnavailable, gin.H{
+			"error": "Firestore call budget exceeded before any results were read: " + err.Error() + " (see MAX_FIRESTORE_CALLS)",
+			"code":  "FIRESTORE_CALL_BUDGET_EXCEEDED",
+		})
+		return
+	}
+
+	var apiErr *services.APIError
+	if errors.Is(err, services.ErrDatabaseNotFound) && errors.As(err, &apiErr) {
+		respond(c, http.StatusNotFound, withFirestoreError(gin.H{
+			"error": fmt.Sprintf("Firestore database %q not found: %s", apiErr.Database, apiErr.Message),
+		}, apiErr))
+		return
+	}
+	if errors.Is(err, services.ErrQuotaExceeded) && errors.As(err, &apiErr) {
+		if apiErr.RetryAfter != "" {
+			c.Header("Retry-After", apiErr.RetryAfter)
+		}
+		respond(c, http.StatusTooManyRequests, withFirestoreError(gin.H{
+			"error": "Firestore quota exceeded: " + apiErr.Message,
+			"code":  "FIRESTORE_QUOTA_EXCEEDED",
+		}, apiErr))
+		return
+	}
+	body := gin.H{"error": err.Error()}
+	if errors.As(err, &apiErr) {
+		withFirestoreError(body, apiErr)
+	}
+	respond(c, http.StatusInternalServerError, body)
+}
+
+// withFirestoreError adds the status, message and details of Firestore's error
+// object to body under "firestore", when the error response carried one.
+func withFirestoreError(body gin.H, apiErr *services.APIError) gin.H {
+	if apiErr.Status == "" && apiErr.Message == "" {
+		return body
+	}
+	details := apiErr.Details
+	if details == nil {
+		details = []interface{}{}
+	}
+	body["firestore"] = gin.H{
+		"status":  apiErr.Status,
+		"message": apiErr.Message,
+		"details": details,
+	}
+	return body
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondFirestoreErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"read timeout", fmt.Errorf("failed to make request: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{"token timeout", fmt.Errorf("failed to get access token: %w", fmt.Errorf("failed to generate access token: %w", context.DeadlineExceeded)), http.StatusGatewayTimeout},
+		{"call budget", fmt.Errorf("restaurants: %w", services.ErrBudgetExceeded), http.StatusServiceUnavailable},
+		{"other", errors.New("unexpected response"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/", nil)
+			respondFirestoreError(c, tt.err)
+			if w.Code != tt.want {
+				t.Errorf("respondFirestoreError(%v) status = %d, want %d", tt.err, w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRespondWrapsOnlySuccessfulGetsAsJSONP(t *testing.T) {
+	tests := []struct {
+		method, callback string
+		code             int
+		wantCode         int
+		wantPrefix       string
+	}{
+		{"GET", "app.handleData", http.StatusOK, http.StatusOK, "/**/app.handleData("},
+		{"GET", "app.handleData", http.StatusNotFound, http.StatusNotFound, "{"},
+		{"GET", "app.handleData", http.StatusInternalServerError, http.StatusInternalServerError, "{"},
+		{"POST", "app.handleData", http.StatusOK, http.StatusOK, "{"},
+		{"GET", "alert(1)//", http.StatusOK, http.StatusBadRequest, "{"},
+		{"GET", "alert(1)//", http.StatusNotFound, http.StatusNotFound, "{"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(tt.method, "/?callback="+tt.callback, nil)
+		respond(c, tt.code, gin.H{"status": "ok"})
+		if w.Code != tt.wantCode || !strings.HasPrefix(w.Body.String(), tt.wantPrefix) {
+			t.Errorf("%s callback=%s %d: got %d %q, want %d starting %q", tt.method, tt.callback, tt.code, w.Code, w.Body.String(), tt.wantCode, tt.wantPrefix)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// simpleJSONTarget is a single series requested by Grafana.
+type simpleJSONTarget struct {
+	Target string `json:"target"`
+	Alias  string `json:"alias"`
+}
+
+// simpleJSONQuery is the body of a Grafana SimpleJSON /query request.
+type simpleJSONQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	IntervalMs int64              `json:"intervalMs"`
+	Targets    []simpleJSONTarget `json:"targets"`
+}
+
+// SimpleJSONSearchHandler lists the available targets (order subcollection IDs).
+func SimpleJSONSearchHandler(c *gin.Context, projectID, databaseID string) {
+	parent := settings(c).Getenv("ORDERS_PARENT")
+	if parent == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "ORDERS_PARENT must be set"})
+		return
+	}
+
+	ctx, state, ok := firestoreContext(c, "search")
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	ids, err := services.DistinctSubcollectionIDs(ctx, projectID, databaseID, parent)
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+
+	state.markHeaders(c)
+	respondDocuments(c, ids, len(ids))
+}
+
+// SimpleJSONQueryHandler returns order counts over time for each target, where
+// a target is an order subcollection ID. Series are named after the target
+// unless an alias is given per target or with the ?alias= param.
+func SimpleJSONQueryHandler(c *gin.Context, backend services.FirestoreBackend) {
+	var query simpleJSONQuery
+	if err := c.ShouldBindJSON(&query); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": "invalid query: " + err.Error()})
+		return
+	}
+
+	defaultTimeField := settings(c).Getenv("SIMPLEJSON_TIME_FIELD")
+	if defaultTimeField == "" {
+		defaultTimeField = "createdAt"
+	}
+	fields := settings(c).Fields
+	interval := time.Duration(query.IntervalMs) * time.Millisecond
+
+	ctx, state, ok := firestoreContext(c, "query")
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	// Fetch the targets' subcollections in parallel, at most
+	// SUMMARY_CONCURRENCY at a time
+	fetched := make([][]services.FirestoreDocument, len(query.Targets))
+	errs := make([]error, len(query.Targets))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, summaryConcurrency(settings(c)))
+	for i, target := range query.Targets {
+		if target.Target == "" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, target string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fetched[i], errs[i] = backend.FetchSubcollection(ctx, target)
+		}(i, target.Target)
+	}
+	wg.Wait()
+
+	series := []gin.H{}
+	for i, target := range query.Targets {
+		if target.Target == "" {
+			continue
+		}
+
+		documents, err := fetched[i], errs[i]
+		// Return the series fetched so far once the call budget runs out
+		if errors.Is(err, services.ErrBudgetExceeded) {
+			break
+		}
+		if err != nil {
+			respondFirestoreError(c, err)
+			return
+		}
+
+		alias := target.Alias
+		if alias == "" {
+			alias = c.Query("alias")
+		}
+
+		series = append(series, gin.H{
+			"target":     seriesName(alias, target.Target, documents),
+			"datapoints": services.CountSeries(documents, fields.TimeField(target.Target, defaultTimeField), query.Range.From, query.Range.To, interval),
+		})
+	}
+
+	state.markHeaders(c)
+	respond(c, http.StatusOK, series)
+}
+
+// seriesName renders a series alias. {target} is replaced by the raw target and
+// any other {field} placeholder by that field's value in the first document.
+func seriesName(alias, target string, documents []services.FirestoreDocument) string {
+	if alias == "" {
+		return target
+	}
+
+	var decoded map[string]interface{}
+	if len(documents) > 0 {
+		decoded, _ = services.DecodeFields(documents[0].Fields)
+	}
+
+	return renderTemplate(alias, decoded, map[string]string{"target": target})
+}
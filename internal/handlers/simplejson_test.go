@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"crossfire-grafana/internal/services"
+)
+
+func TestSimpleJSONQueryCountsByCollectionTimeField(t *testing.T) {
+	// Each document's createdAt and datePosted fall in different hours
+	document := func(id, createdAt, datePosted string) services.FirestoreDocument {
+		return services.FirestoreDocument{ID: id, Fields: map[string]interface{}{
+			"createdAt":  map[string]interface{}{"timestampValue": createdAt},
+			"datePosted": map[string]interface{}{"timestampValue": datePosted},
+		}}
+	}
+	documents := []services.FirestoreDocument{
+		document("a", "2024-12-16T00:10:00Z", "2024-12-16T01:10:00Z"),
+		document("b", "2024-12-16T00:20:00Z", "2024-12-16T01:20:00Z"),
+	}
+	backend := fakeBackend{subcollections: map[string][]services.FirestoreDocument{
+		"orders":      documents,
+		"restaurants": documents,
+	}}
+
+	tests := []struct {
+		target, timeField string
+		want              []float64
+	}{
+		{"orders", "", []float64{0, 2}},
+		{"restaurants", "", []float64{2, 0}},
+		{"restaurants", "datePosted", []float64{0, 2}},
+	}
+	for _, tt := range tests {
+		query := `{"range": {"from": "2024-12-16T00:00:00Z", "to": "2024-12-16T01:59:59Z"}, "intervalMs": 3600000, "targets": [{"target": "` + tt.target + `"}]}`
+		c, w := testRequest("POST", "/query", strings.NewReader(query), map[string]string{"SIMPLEJSON_TIME_FIELD": tt.timeField}, timeFields)
+		SimpleJSONQueryHandler(c, backend)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", tt.target, w.Code, w.Body.String())
+		}
+		var series []struct{ Datapoints [][]float64 }
+		json.Unmarshal(w.Body.Bytes(), &series)
+		var counts []float64
+		for _, point := range series[0].Datapoints {
+			counts = append(counts, point[0])
+		}
+		if len(counts) != len(tt.want) || counts[0] != tt.want[0] || counts[1] != tt.want[1] {
+			t.Errorf("%s with SIMPLEJSON_TIME_FIELD=%q: hourly counts = %v, want %v", tt.target, tt.timeField, counts, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SimpleJSONTagKeysHandler lists the fields of the ad-hoc filter collection as
+// Grafana ad-hoc filter keys, with the type inferred from their values. Nested
+// maps contribute dotted keys (billTo.state); fields FILTERABLE_FIELDS does
+// not allow are left out.
+func SimpleJSONTagKeysHandler(c *gin.Context, backend services.FirestoreBackend) {
+	collection := adhocCollection(c)
+
+	ctx, state, ok := firestoreContext(c, collection)
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	documents, _, err := backend.FetchCollection(ctx, collection)
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+
+	values := make(map[string][]interface{})
+	for _, doc := range documents {
+		decoded, err := services.DecodeFields(doc.Fields)
+		if err != nil {
+			continue
+		}
+		collectFieldValues(values, "", decoded)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if services.CheckFilterable(c.Request.Context(), collection, key) == nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	tagKeys := make([]gin.H, len(keys))
+	for i, key := range keys {
+		tagKeys[i] = gin.H{"type": columnType(values[key]), "text": key}
+	}
+	state.markHeaders(c)
+	respond(c, http.StatusOK, tagKeys)
+}
+
+// SimpleJSONTagValuesHandler lists the distinct values of the key in the body
+// ({"key": "billTo.state"}) across the ad-hoc filter collection, as Grafana
+// ad-hoc filter values.
+func SimpleJSONTagValuesHandler(c *gin.Context, backend services.FirestoreBackend) {
+	var query struct {
+		Key string `json:"key"`
+	}
+	if err := c.ShouldBindJSON(&query); err != nil || query.Key == "" {
+		respond(c, http.StatusBadRequest, gin.H{"error": "key is required"})
+		return
+	}
+	collection := adhocCollection(c)
+	if err := services.CheckFilterable(c.Request.Context(), collection, query.Key); err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, state, ok := firestoreContext(c, collection)
+	if !ok {
+		return
+	}
+	defer state.done()
+
+	documents, _, err := backend.FetchCollection(ctx, collection)
+	if err != nil {
+		respondFirestoreError(c, err)
+		return
+	}
+
+	tagValues := []gin.H{}
+	for _, value := range services.DistinctValues(documents, query.Key) {
+		tagValues = append(tagValues, gin.H{"text": valueText(value)})
+	}
+	state.markHeaders(c)
+	respond(c, http.StatusOK, tagValues)
+}
+
+// collectFieldValues adds the values of decoded to values under their dotted
+// paths, descending into maps. Array elements count as values of the array's
+// key, as with DistinctValues. Names containing dots cannot be addressed by a
+// dotted path and are skipped.
+func collectFieldValues(values map[string][]interface{}, prefix string, decoded map[string]interface{}) {
+	for name, value := range decoded {
+		if strings.Contains(name, ".") {
+			continue
+		}
+		key := prefix + name
+		switch v := value.(type) {
+		case map[string]interface{}:
+			collectFieldValues(values, key+".", v)
+		case []interface{}:
+			values[key] = append(values[key], v...)
+		default:
+			values[key] = append(values[key], v)
+		}
+	}
+}
+
+// adhocCollection returns the collection Grafana's ad-hoc filters read from:
+// ?collection, ADHOC_FILTER_COLLECTION, or "restaurants".
+func adhocCollection(c *gin.Context) string {
+	if collection := c.Query("collection"); collection != "" {
+		return collection
+	}
+	if collection := settings(c).Getenv("ADHOC_FILTER_COLLECTION"); collection != "" {
+		return collection
+	}
+	return "restaurants"
+}
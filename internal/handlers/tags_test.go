@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"crossfire-grafana/internal/services"
+)
+
+// restaurantFixtures is a sample ad-hoc filter collection in Firestore's typed JSON.
+var restaurantFixtures = fakeBackend{collections: map[string][]services.FirestoreDocument{
+	"restaurants": {
+		{ID: "a", Fields: map[string]interface{}{
+			"name":    map[string]interface{}{"stringValue": "Thai Palace"},
+			"rating":  map[string]interface{}{"integerValue": "4"},
+			"open":    map[string]interface{}{"booleanValue": true},
+			"cuisine": map[string]interface{}{"arrayValue": map[string]interface{}{"values": []interface{}{map[string]interface{}{"stringValue": "thai"}, map[string]interface{}{"stringValue": "vegan"}}}},
+			"billTo":  map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{"state": map[string]interface{}{"stringValue": "NSW"}}}},
+		}},
+		{ID: "b", Fields: map[string]interface{}{
+			"name":    map[string]interface{}{"stringValue": "Pho House"},
+			"rating":  map[string]interface{}{"doubleValue": 3.5},
+			"cuisine": map[string]interface{}{"arrayValue": map[string]interface{}{"values": []interface{}{map[string]interface{}{"stringValue": "thai"}}}},
+			"billTo":  map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{"state": map[string]interface{}{"stringValue": "VIC"}}}},
+			"odd.key": map[string]interface{}{"stringValue": "skipped"},
+		}},
+	},
+}}
+
+func TestSimpleJSONTagKeys(t *testing.T) {
+	tests := []struct {
+		filterable string
+		want       []string
+	}{
+		{"", []string{"billTo.state:string", "cuisine:string", "name:string", "open:boolean", "rating:number"}},
+		{"restaurants=name,billTo.state", []string{"billTo.state:string", "name:string"}},
+	}
+	for _, tt := range tests {
+		c, w := testRequest("POST", "/tag-keys", strings.NewReader(`{}`), map[string]string{"FILTERABLE_FIELDS": tt.filterable}, nil)
+		SimpleJSONTagKeysHandler(c, restaurantFixtures)
+		if w.Code != http.StatusOK {
+			t.Fatalf("FILTERABLE_FIELDS=%q: status = %d: %s", tt.filterable, w.Code, w.Body.String())
+		}
+		var keys []struct{ Type, Text string }
+		json.Unmarshal(w.Body.Bytes(), &keys)
+		var got []string
+		for _, key := range keys {
+			got = append(got, key.Text+":"+key.Type)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FILTERABLE_FIELDS=%q: keys = %q, want %q", tt.filterable, got, tt.want)
+		}
+	}
+}
+
+func TestSimpleJSONTagValues(t *testing.T) {
+	tests := []struct {
+		key      string
+		wantCode int
+		want     []string
+	}{
+		{"billTo.state", http.StatusOK, []string{"NSW", "VIC"}},
+		{"cuisine", http.StatusOK, []string{"thai", "vegan"}},
+		{"rating", http.StatusOK, []string{"3.5", "4"}},
+		{"missing", http.StatusOK, nil},
+		{"", http.StatusBadRequest, nil},
+		{"open", http.StatusBadRequest, nil},
+	}
+	env := map[string]string{"FILTERABLE_FIELDS": "restaurants=billTo.state,cuisine,rating,missing"}
+	for _, tt := range tests {
+		body, _ := json.Marshal(map[string]string{"key": tt.key})
+		c, w := testRequest("POST", "/tag-values", strings.NewReader(string(body)), env, nil)
+		SimpleJSONTagValuesHandler(c, restaurantFixtures)
+		if w.Code != tt.wantCode {
+			t.Errorf("key %q: status = %d, want %d: %s", tt.key, w.Code, tt.wantCode, w.Body.String())
+			continue
+		}
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+		var values []struct{ Text string }
+		json.Unmarshal(w.Body.Bytes(), &values)
+		var got []string
+		for _, value := range values {
+			got = append(got, value.Text)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("key %q: values = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// whereParams reads the repeatable ?where=field:OP:value filters, ANDed
+// together. The operator is a Firestore operator such as EQUAL or
+// GREATER_THAN (case-insensitive); unary operators such as IS_NULL take no
+// value. The value is read as JSON when it parses (numbers, true, false,
+// null, "quoted strings", [arrays] for IN) and as a plain string otherwise.
+func whereParams(c *gin.Context) ([]services.Filter, error) {
+	var filters []services.Filter
+	for _, raw := range c.QueryArray("where") {
+		field, rest, found := strings.Cut(raw, ":")
+		if !found || field == "" {
+			return nil, fmt.Errorf("invalid where %q, expected field:OP:value", raw)
+		}
+		op, value, hasValue := strings.Cut(rest, ":")
+		filter := services.Filter{Field: field, Op: strings.ToUpper(op)}
+		if hasValue {
+			filter.Value = whereValue(value)
+		} else if !strings.HasPrefix(filter.Op, "IS_") {
+			return nil, fmt.Errorf("invalid where %q, %s requires a value", raw, filter.Op)
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// whereValue parses a where value as JSON, falling back to the raw string.
+// Numbers are kept as json.Number so large integers keep every digit.
+func whereValue(raw string) interface{} {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil || dec.More() {
+		return raw
+	}
+	return value
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// queryContext returns a gin context for a GET request with the given query string.
+func queryContext(query url.Values) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/?"+query.Encode(), nil)
+	return c
+}
+
+func TestWhereParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		where []string
+		want  []services.Filter
+	}{
+		{"none", nil, nil},
+		{"string", []string{"billTo.state:equal:NSW"}, []services.Filter{{Field: "billTo.state", Op: "EQUAL", Value: "NSW"}}},
+		{"number", []string{"total:GREATER_THAN:12345678901234567"}, []services.Filter{{Field: "total", Op: "GREATER_THAN", Value: json.Number("12345678901234567")}}},
+		{"quoted", []string{`code:EQUAL:"123"`}, []services.Filter{{Field: "code", Op: "EQUAL", Value: "123"}}},
+		{"array", []string{`state:IN:["NSW","VIC"]`}, []services.Filter{{Field: "state", Op: "IN", Value: []interface{}{"NSW", "VIC"}}}},
+		{"value with colons", []string{"time:EQUAL:09:30"}, []services.Filter{{Field: "time", Op: "EQUAL", Value: "09:30"}}},
+		{"unary", []string{"deletedAt:is_null"}, []services.Filter{{Field: "deletedAt", Op: "IS_NULL"}}},
+		{"several", []string{"a:EQUAL:true", "b:LESS_THAN:null"}, []services.Filter{{Field: "a", Op: "EQUAL", Value: true}, {Field: "b", Op: "LESS_THAN", Value: nil}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := whereParams(queryContext(url.Values{"where": tt.where}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("whereParams(%q) = %#v, want %#v", tt.where, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhereParamsRejectsMalformedFilters(t *testing.T) {
+	for _, where := range []string{"state", ":EQUAL:NSW", "state:EQUAL"} {
+		if got, err := whereParams(queryContext(url.Values{"where": {where}})); err == nil {
+			t.Errorf("whereParams(%q) = %v, want an error", where, got)
+		}
+	}
+}
+
+func TestWhereFiltersHonourAllowedOperators(t *testing.T) {
+	fakeFirestore(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+	env := map[string]string{"ALLOWED_FILTER_OPS": "EQUAL,IN"}
+
+	tests := []struct {
+		target    string
+		forbidden bool
+	}{
+		{"/aggregate?collection=orders&op=sum&field=total&where=status:EQUAL:open", false},
+		{"/aggregate?collection=orders&op=sum&field=total&where=total:GREATER_THAN:4", true},
+		{"/restaurants-cache?where=cuisine:in:[\"thai\"]", false},
+		{"/restaurants-cache?where=rating:LESS_THAN:2", true},
+	}
+	for _, tt := range tests {
+		c, w := testRequest("GET", tt.target, nil, env, nil)
+		if strings.HasPrefix(tt.target, "/aggregate") {
+			AggregateHandler(c, "p", "d")
+		} else {
+			RestaurantsCacheHandler(c, fakeBackend{}, "p", "d")
+		}
+		rejected := w.Code == http.StatusBadRequest && strings.Contains(w.Body.String(), "is not allowed")
+		if rejected != tt.forbidden {
+			t.Errorf("%s: status %d %s, want rejected %v", tt.target, w.Code, w.Body.String(), tt.forbidden)
+		}
+	}
+}
+
+// listingBackend records the collections listed through it.
+type listingBackend struct {
+	fakeBackend
+	listed []string
+}
+
+func (b *listingBackend) FetchCollection(ctx context.Context, collection string) ([]services.FirestoreDocument, bool, error) {
+	b.listed = append(b.listed, collection)
+	return b.fakeBackend.FetchCollection(ctx, collection)
+}
+
+func TestRestaurantsCacheQueriesOnlyWithFilters(t *testing.T) {
+	var queries []string
+	fakeFirestore(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		queries = append(queries, string(body))
+		w.Write([]byte(`[{"document": {"name": "projects/p/databases/d/documents/restaurants/a", "fields": {"cuisine": {"stringValue": "thai"}}}}]`))
+	})
+
+	tests := []struct {
+		query      string
+		wantQuery  string
+		wantListed bool
+	}{
+		{"", "", true},
+		{"sort=name", "", true},
+		{"where=cuisine:EQUAL:thai", `"fieldFilter":{"field":{"fieldPath":"cuisine"},"op":"EQUAL","value":{"stringValue":"thai"}}`, false},
+		{"where=rating:GREATER_THAN:4&where=open:EQUAL:true", `"compositeFilter":{"filters":[`, false},
+	}
+	for _, tt := range tests {
+		queries = nil
+		backend := &listingBackend{fakeBackend: restaurantFixtures}
+		c, w := testRequest("GET", "/restaurants-cache?"+tt.query, nil, nil, nil)
+		RestaurantsCacheHandler(c, backend, "p", "d")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: status = %d: %s", tt.query, w.Code, w.Body.String())
+		}
+
+		if listed := len(backend.listed) > 0; listed != tt.wantListed {
+			t.Errorf("%q: listed the collection %v, want %v", tt.query, listed, tt.wantListed)
+		}
+		if tt.wantQuery == "" {
+			if len(queries) > 0 {
+				t.Errorf("%q: ran %d queries, want none", tt.query, len(queries))
+			}
+			continue
+		}
+		if len(queries) != 1 || !strings.Contains(queries[0], tt.wantQuery) {
+			t.Errorf("%q: queries = %q, want one containing %s", tt.query, queries, tt.wantQuery)
+		}
+	}
+}
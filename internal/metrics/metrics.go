@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// registry holds every metric created by this package.
+var registry = prometheus.NewRegistry()
+
+// Handler serves the registered metrics in the Prometheus text format, or in
+// OpenMetrics when the scraper's Accept header asks for it.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// Counter is a monotonically increasing counter with a single label.
+type Counter struct {
+	vec *prometheus.CounterVec
+}
+
+// NewCounter creates and registers a counter partitioned by label.
+func NewCounter(name, help, label string) *Counter {
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, []string{label})
+	registry.MustRegister(vec)
+	return &Counter{vec: vec}
+}
+
+// Inc increments the counter for a label value.
+func (c *Counter) Inc(labelValue string) {
+	c.vec.WithLabelValues(labelValue).Inc()
+}
+
+// Snapshot returns the counter's current values by label value.
+func (c *Counter) Snapshot() map[string]float64 {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.vec.Collect(ch)
+		close(ch)
+	}()
+
+	snapshot := make(map[string]float64)
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil || len(m.GetLabel()) == 0 {
+			continue
+		}
+		snapshot[m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+	}
+	return snapshot
+}
+
+// GaugeFunc is a gauge whose values are computed when metrics are scraped.
+type GaugeFunc struct {
+	desc *prometheus.Desc
+	fn   func() map[string]float64
+}
+
+// NewGaugeFunc creates and registers a gauge partitioned by label whose values
+// are returned by fn at scrape time.
+func NewGaugeFunc(name, help, label string, fn func() map[string]float64) *GaugeFunc {
+	g := &GaugeFunc{desc: prometheus.NewDesc(name, help, []string{label}, nil), fn: fn}
+	registry.MustRegister(g)
+	return g
+}
+
+// Describe implements prometheus.Collector.
+func (g *GaugeFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+// Collect implements prometheus.Collector.
+func (g *GaugeFunc) Collect(ch chan<- prometheus.Metric) {
+	for labelValue, value := range g.fn() {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, value, labelValue)
+	}
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var (
+	testCounter = NewCounter("test_requests_total", "Requests seen by the test.", "route")
+	_           = NewGaugeFunc("test_ratio", "Ratio reported by the test.", "route", func() map[string]float64 {
+		return map[string]float64{"/a": 0.5}
+	})
+)
+
+func TestCounterSnapshot(t *testing.T) {
+	testCounter.Inc("/a")
+	testCounter.Inc("/a")
+	testCounter.Inc("/b")
+
+	snapshot := testCounter.Snapshot()
+	if snapshot["/a"] != 2 || snapshot["/b"] != 1 || len(snapshot) != 2 {
+		t.Fatalf("Snapshot = %v, want /a=2 and /b=1", snapshot)
+	}
+}
+
+func TestHandlerNegotiatesFormat(t *testing.T) {
+	testCounter.Inc("/a")
+
+	tests := []struct {
+		accept, contentType string
+		want                []string
+	}{
+		{"", "text/plain", []string{`test_requests_total{route="/a"}`, `test_ratio{route="/a"} 0.5`}},
+		{"application/openmetrics-text; version=1.0.0", "application/openmetrics-text", []string{
+			"# TYPE test_requests counter", `test_requests_total{route="/a"}`, "# EOF",
+		}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rec := httptest.NewRecorder()
+		Handler().ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) {
+			t.Errorf("Accept %q: Content-Type = %q, want %s", tt.accept, got, tt.contentType)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("Accept %q: body does not contain %q:\n%s", tt.accept, want, rec.Body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimingsKey is the gin context key under which handlers leave a summary of
+// the request's timings for the request log line.
+const TimingsKey = "timings"
+
+// RequestLogger logs completed requests. Successful requests are sampled at
+// 1 in sampleRate (LOG_SAMPLE_RATE), while errors and requests slower than
+// slowThreshold (LOG_SLOW_THRESHOLD) are always logged. Timings a handler left
+// under TimingsKey are appended to the line.
+func RequestLogger(sampleRate uint64, slowThreshold time.Duration) gin.HandlerFunc {
+	if sampleRate == 0 {
+		sampleRate = 1
+	}
+
+	var counter atomic.Uint64
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+		status := c.Writer.Status()
+		timings := ""
+		if value := c.GetString(TimingsKey); value != "" {
+			timings = " " + value
+		}
+
+		switch {
+		case status >= 400:
+			log.Printf("ERROR %d %s %s (%v)%s", status, c.Request.Method, c.Request.URL.Path, latency, timings)
+		case latency > slowThreshold:
+			log.Printf("SLOW %d %s %s (%v)%s", status, c.Request.Method, c.Request.URL.Path, latency, timings)
+		case counter.Add(1)%sampleRate == 0:
+			log.Printf("%d %s %s (%v)%s", status, c.Request.Method, c.Request.URL.Path, latency, timings)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AllowParams rejects requests carrying query parameters outside allowed with
+// 400, listing each unknown parameter with the closest allowed name when it
+// looks like a typo (e.g. subColection -> subCollection).
+func AllowParams(allowed ...string) gin.HandlerFunc {
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		allowedSet[name] = true
+	}
+
+	return func(c *gin.Context) {
+		var unknown []string
+		for name := range c.Request.URL.Query() {
+			if !allowedSet[name] {
+				unknown = append(unknown, name)
+			}
+		}
+		if len(unknown) == 0 {
+			c.Next()
+			return
+		}
+		sort.Strings(unknown)
+
+		problems := make([]string, len(unknown))
+		for i, name := range unknown {
+			problems[i] = name
+			if suggestion := closestParam(name, allowed); suggestion != "" {
+				problems[i] += fmt.Sprintf(" (did you mean %s?)", suggestion)
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":   "unknown query parameters: " + strings.Join(problems, ", "),
+			"allowed": allowed,
+		})
+	}
+}
+
+// closestParam returns the allowed name nearest to name by edit distance,
+// or "" when none is close enough to be a likely typo.
+func closestParam(name string, allowed []string) string {
+	best, bestDistance := "", -1
+	for _, candidate := range allowed {
+		distance := levenshtein(strings.ToLower(name), strings.ToLower(candidate))
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if bestDistance < 0 || bestDistance > max(2, len(name)/3) {
+		return ""
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClosestParam(t *testing.T) {
+	allowed := []string{"subCollection", "sort", "dir", "decode", "flatten"}
+	tests := []struct {
+		name, want string
+	}{
+		{"subColection", "subCollection"},
+		{"SUBCOLLECTION", "subCollection"},
+		{"srot", "sort"},
+		{"flaten", "flatten"},
+		{"limit", ""},
+		{"dri", "dir"},
+		{"x", ""},
+		{"somethingElseEntirely", ""},
+	}
+	for _, tt := range tests {
+		if got := closestParam(tt.name, allowed); got != tt.want {
+			t.Errorf("closestParam(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := closestParam("sort", nil); got != "" {
+		t.Errorf("closestParam with nothing allowed = %q, want none", got)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"sort", "", 4},
+		{"sort", "sort", 0},
+		{"srot", "sort", 2},
+		{"kitten", "sitting", 3},
+		{"état", "etat", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestAllowParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", AllowParams("sort", "dir"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		query    string
+		status   int
+		contains string
+	}{
+		{"", http.StatusOK, ""},
+		{"sort=name&dir=desc", http.StatusOK, ""},
+		{"srot=name", http.StatusBadRequest, "srot (did you mean sort?)"},
+		{"sort=name&zzzzzz=1&limit=5", http.StatusBadRequest, "unknown query parameters: limit, zzzzzz"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/?"+tt.query, nil))
+		if w.Code != tt.status {
+			t.Errorf("GET /?%s = %d, want %d", tt.query, w.Code, tt.status)
+			continue
+		}
+		if tt.contains == "" {
+			continue
+		}
+		var body struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || !strings.Contains(body.Error, tt.contains) {
+			t.Errorf("GET /?%s error = %q, want it to contain %q", tt.query, body.Error, tt.contains)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"crossfire-grafana/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// RuntimeSnapshot pins the configuration snapshot current when the request
+// arrives on its context, so every setting the request reads comes from the
+// same snapshot even when /admin/reload swaps in a new one mid-request.
+func RuntimeSnapshot() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(config.WithRuntime(c.Request.Context(), config.Current()))
+		c.Next()
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestTimeout bounds each request's context by its route's timeout in
+// routes, keyed by the registered route pattern, falling back to fallback.
+// Routes with neither are left unbounded here; their Firestore reads are still
+// limited by the read timeouts.
+func RequestTimeout(fallback time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout, ok := routes[c.FullPath()]
+		if !ok {
+			timeout = fallback
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// ClientDeadline bounds the request's context by the timeout the client sends
+// in X-Request-Timeout, as a duration ("10s") or a number of seconds, e.g.
+// Grafana's query timeout set as a datasource header. Firestore calls then stop
+// when the client stops waiting instead of running to completion. Missing or
+// invalid values leave the context as it is.
+func ClientDeadline() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := clientTimeout(c.GetHeader("X-Request-Timeout"))
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// clientTimeout parses an X-Request-Timeout value, 0 when it is not valid.
+func clientTimeout(raw string) time.Duration {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(raw, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0
+	}
+	return value
+}
@@ -0,0 +1,28 @@
+package routes
+
+// Query parameters accepted by each kind of route, enforced with
+// middleware.AllowParams so typos fail loudly instead of being ignored.
+var (
+	// firestoreParams apply to every Firestore-backed route. format selects
+	// the response encoding, and on document listings also their shape.
+	firestoreParams = []string{"pretty", "callback", "debug", "explain", "readTime", "profile", "format"}
+
+	// shapeParams control how documents are shaped in the response.
+	shapeParams = []string{"decode", "coerce", "flatten", "collapseSingletonArrays", "alias", "aliasStrict", "derive", "path", "partitionBy"}
+
+	// filterParams narrow a listing read from the backend; routes that read a
+	// single document or join two collections don't accept them.
+	filterParams = []string{"contains", "modifiedSince"}
+
+	// sortingParams sort a document listing.
+	sortingParams = []string{"sort", "dir"}
+)
+
+// params concatenates parameter lists.
+func params(lists ...[]string) []string {
+	var all []string
+	for _, list := range lists {
+		all = append(all, list...)
+	}
+	return all
+}
@@ -1,31 +1,193 @@
 package routes
 
 import (
-	"github.com/gin-gonic/gin"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"crossfire-grafana/internal/config"
 	"crossfire-grafana/internal/handlers"
+	"crossfire-grafana/internal/middleware"
+	"crossfire-grafana/internal/services"
+	"github.com/gin-gonic/gin"
 )
 
-// SetupRouter configures the Gin router.
-func SetupRouter(projectID, databaseID string) *gin.Engine {
-	router := gin.Default()
+// SetupRouter configures the Gin router, including any routes defined in the
+// routes config file. It fails if a configured route collides with another
+// route, or if ROUTE_TIMEOUTS names a route that is not registered.
+func SetupRouter(cfg config.Config, backend services.FirestoreBackend, warmer *services.CacheWarmer, configured []config.RouteConfig) (*gin.Engine, error) {
+	projectID, databaseID := cfg.ProjectID, cfg.DatabaseID
+
+	router := gin.New()
+	router.Use(gin.Recovery(), middleware.RuntimeSnapshot(), middleware.RequestLogger(cfg.LogSampleRate, cfg.LogSlowThreshold), middleware.RequestTimeout(cfg.RequestTimeout, cfg.RouteTimeouts), middleware.ClientDeadline())
 
 	// Base route
 	router.GET("/", handlers.HomeHandler)
 
+	// Version route
+	router.GET("/version", func(c *gin.Context) {
+		handlers.VersionHandler(c, projectID, databaseID)
+	})
+
+	// Health check route
+	router.GET("/healthz", func(c *gin.Context) {
+		handlers.HealthzHandler(c, projectID, databaseID)
+	})
+
+	// Liveness and readiness probes
+	router.GET("/livez", handlers.LivezHandler)
+	router.GET("/ready", func(c *gin.Context) {
+		handlers.ReadyHandler(c, warmer)
+	})
+
+	// Metrics route
+	router.GET("/metrics", handlers.MetricsHandler)
+
 	// Restaurants cache route
-	router.GET("/restaurants-cache", func(c *gin.Context) {
-		handlers.RestaurantsCacheHandler(c, projectID, databaseID)
+	router.GET("/restaurants-cache", middleware.AllowParams(params(firestoreParams, shapeParams, filterParams, sortingParams, []string{"idsOnly", "where"})...), func(c *gin.Context) {
+		handlers.RestaurantsCacheHandler(c, backend, projectID, databaseID)
 	})
 
 	// Latest orders route
-	router.GET("/latest-orders", func(c *gin.Context) {
-		handlers.LatestOrdersHandler(c, projectID, databaseID)
+	router.GET("/latest-orders", middleware.AllowParams(params(firestoreParams, shapeParams, filterParams, sortingParams, []string{"subCollection", "idsOnly"})...), func(c *gin.Context) {
+		handlers.LatestOrdersHandler(c, backend, projectID, databaseID)
 	})
 
 	// Dead letters route
-	router.GET("/dead-letters-specific", func(c *gin.Context) {
-		handlers.DeadLettersHandler(c, projectID, databaseID)
+	router.GET("/dead-letters-specific", middleware.AllowParams(params(firestoreParams, shapeParams, filterParams, []string{"subCollection", "limit", "cursor"})...), func(c *gin.Context) {
+		handlers.DeadLettersHandler(c, backend, projectID, databaseID)
+	})
+
+	// Dead letter counts per date route
+	router.GET("/dead-letters/by-date", middleware.AllowParams(params(firestoreParams, []string{"parent"})...), func(c *gin.Context) {
+		handlers.DeadLettersByDateHandler(c, projectID, databaseID)
+	})
+
+	// Structured query route
+	router.POST("/query/structured", middleware.AllowParams(params(firestoreParams, shapeParams, filterParams, []string{"limitToLast"})...), func(c *gin.Context) {
+		handlers.StructuredQueryHandler(c, projectID, databaseID)
+	})
+
+	// Distinct field values routes
+	router.GET("/collections/:name/distinct", middleware.AllowParams(params(firestoreParams, []string{"field"})...), func(c *gin.Context) {
+		handlers.DistinctValuesHandler(c, backend)
+	})
+	router.GET("/distinct", middleware.AllowParams(params(firestoreParams, []string{"collection", "field"})...), func(c *gin.Context) {
+		handlers.DistinctQueryHandler(c, backend)
+	})
+
+	// Collection count route
+	router.GET("/collections/:name/count", middleware.AllowParams(params(firestoreParams, []string{"allDescendants"})...), func(c *gin.Context) {
+		handlers.CollectionCountHandler(c, projectID, databaseID)
+	})
+
+	// Field aggregation route
+	router.GET("/aggregate", middleware.AllowParams(params(firestoreParams, []string{"collection", "op", "field", "allDescendants", "where"})...), func(c *gin.Context) {
+		handlers.AggregateHandler(c, projectID, databaseID)
+	})
+
+	// Collection field range route
+	router.GET("/collections/:name/range", middleware.AllowParams(params(firestoreParams, []string{"field", "allDescendants"})...), func(c *gin.Context) {
+		handlers.CollectionRangeHandler(c, projectID, databaseID)
 	})
 
-	return router
+	// Collection freshness route
+	router.GET("/collections/:name/freshness", middleware.AllowParams(params(firestoreParams, []string{"field", "allDescendants"})...), func(c *gin.Context) {
+		handlers.CollectionFreshnessHandler(c, projectID, databaseID)
+	})
+
+	// Single document route
+	router.GET("/documents/*path", middleware.AllowParams(params(firestoreParams, shapeParams, []string{"fields"})...), func(c *gin.Context) {
+		handlers.DocumentHandler(c, projectID, databaseID)
+	})
+
+	// Collection join route
+	router.GET("/join", middleware.AllowParams(params(firestoreParams, shapeParams, sortingParams, []string{"left", "right", "on"})...), func(c *gin.Context) {
+		handlers.JoinHandler(c, backend, projectID, databaseID)
+	})
+
+	// Order subcollection search route
+	router.GET("/search/subcollections", middleware.AllowParams(params(firestoreParams, []string{"parent"})...), func(c *gin.Context) {
+		handlers.SubcollectionsSearchHandler(c, projectID, databaseID)
+	})
+
+	// Grafana SimpleJSON routes
+	router.POST("/search", func(c *gin.Context) {
+		handlers.SimpleJSONSearchHandler(c, projectID, databaseID)
+	})
+	router.POST("/query", func(c *gin.Context) {
+		handlers.SimpleJSONQueryHandler(c, backend)
+	})
+	router.POST("/annotations", func(c *gin.Context) {
+		handlers.SimpleJSONAnnotationsHandler(c, projectID, databaseID)
+	})
+	router.POST("/tag-keys", func(c *gin.Context) {
+		handlers.SimpleJSONTagKeysHandler(c, backend)
+	})
+	router.POST("/tag-values", func(c *gin.Context) {
+		handlers.SimpleJSONTagValuesHandler(c, backend)
+	})
+
+	// Dashboard summary route
+	router.GET("/dashboard/summary", middleware.AllowParams(params(firestoreParams, []string{"consistent"})...), func(c *gin.Context) {
+		handlers.DashboardSummaryHandler(c, backend, projectID, databaseID)
+	})
+
+	// Admin routes
+	admin := router.Group("/admin", handlers.RequireAPIKey)
+	admin.POST("/reload", handlers.AdminReloadHandler)
+	admin.POST("/cache/purge", middleware.AllowParams("collection"), func(c *gin.Context) {
+		handlers.AdminCachePurgeHandler(c, backend)
+	})
+
+	// Config-driven collection routes
+	for _, route := range configured {
+		err := addConfiguredRoute(router, route.Path, middleware.AllowParams(params(firestoreParams, shapeParams, filterParams, sortingParams, []string{"idsOnly"})...), func(c *gin.Context) {
+			handlers.ConfiguredRouteHandler(c, backend, route, projectID, databaseID)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkRouteTimeouts(router, cfg.RouteTimeouts); err != nil {
+		return nil, err
+	}
+	return router, nil
+}
+
+// checkRouteTimeouts fails on a ROUTE_TIMEOUTS entry naming no registered
+// route pattern, which would otherwise leave the intended route unbounded.
+func checkRouteTimeouts(router *gin.Engine, timeouts map[string]time.Duration) error {
+	registered := make(map[string]bool)
+	for _, route := range router.Routes() {
+		registered[route.Path] = true
+	}
+
+	var unknown []string
+	for path := range timeouts {
+		if !registered[path] {
+			unknown = append(unknown, path)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("ROUTE_TIMEOUTS names routes that are not registered: %s (use the route pattern, e.g. /documents/*path)", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// addConfiguredRoute registers a config-driven GET route. gin panics when a
+// path overlaps an existing route, whether it is the same path or conflicts
+// with a wildcard (/documents/x with /documents/*path, /collections/:id with
+// /collections/:name/count); that panic is returned as an error instead.
+func addConfiguredRoute(router *gin.Engine, path string, handlers ...gin.HandlerFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("configured route %s collides with an existing route: %v", path, r)
+		}
+	}()
+	router.GET(path, handlers...)
+	return nil
 }
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"crossfire-grafana/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSetupRouterRejectsCollidingConfiguredRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		path    string
+		collide bool
+	}{
+		{"/stores", false},
+		{"/restaurants-cache", true},
+		{"/documents/x", true},
+		{"/collections/:id", true},
+		{"/collections/:name/latest", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			cfg := config.Config{ProjectID: "project", DatabaseID: "(default)"}
+			route := config.RouteConfig{Path: tt.path, Collection: "stores", Type: "collection"}
+			_, err := SetupRouter(cfg, nil, nil, []config.RouteConfig{route})
+			if tt.collide && (err == nil || !strings.Contains(err.Error(), "collides")) {
+				t.Fatalf("SetupRouter(%s) = %v, want a collision error", tt.path, err)
+			}
+			if !tt.collide && err != nil {
+				t.Fatalf("SetupRouter(%s) = %v, want no error", tt.path, err)
+			}
+		})
+	}
+}
+
+func TestSetupRouterRejectsUnknownRouteTimeouts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	configured := []config.RouteConfig{{Path: "/stores", Collection: "stores", Type: "collection"}}
+	tests := []struct {
+		route   string
+		wantErr bool
+	}{
+		{"/documents/*path", false},
+		{"/collections/:name/count", false},
+		{"/stores", false},
+		{"/documents", true},
+		{"/restaurant-cache", true},
+	}
+	for _, tt := range tests {
+		cfg := config.Config{ProjectID: "project", DatabaseID: "(default)", RouteTimeouts: map[string]time.Duration{tt.route: time.Second}}
+		_, err := SetupRouter(cfg, nil, nil, configured)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SetupRouter with a timeout for %s = %v, want error %v", tt.route, err, tt.wantErr)
+		}
+	}
+}
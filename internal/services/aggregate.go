@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNoNumericValues is returned by SumField and AverageField when no matched
+// document holds a number in the field.
+var ErrNoNumericValues = errors.New("no numeric values")
+
+// CountDocuments counts the documents matched by a QuerySpec with a COUNT(*)
+// aggregation query, without listing them.
+func CountDocuments(ctx context.Context, projectID, databaseID string, spec QuerySpec) (int64, error) {
+	results, err := runAggregation(ctx, projectID, databaseID, spec, map[string]interface{}{
+		"count": map[string]interface{}{"count": map[string]interface{}{}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	count, ok := results["count"].(int64)
+	if !ok {
+		return 0, fmt.Errorf("count aggregation returned %T", results["count"])
+	}
+	return count, nil
+}
+
+// SumField sums a numeric field across the documents matched by a QuerySpec
+// with a SUM aggregation. The result is an int64 when every summed value is an
+// integer and fits, a float64 otherwise.
+func SumField(ctx context.Context, projectID, databaseID string, spec QuerySpec, field string) (interface{}, error) {
+	return numericAggregation(ctx, projectID, databaseID, spec, field, "sum")
+}
+
+// AverageField averages a numeric field across the documents matched by a
+// QuerySpec with an AVG aggregation.
+func AverageField(ctx context.Context, projectID, databaseID string, spec QuerySpec, field string) (interface{}, error) {
+	return numericAggregation(ctx, projectID, databaseID, spec, field, "avg")
+}
+
+// numericAggregation runs a sum or avg aggregation over field. Firestore skips
+// values that are not numbers, so an average is requested alongside: it is
+// null when nothing was aggregated, which is reported as ErrNoNumericValues
+// rather than a misleading zero sum.
+func numericAggregation(ctx context.Context, projectID, databaseID string, spec QuerySpec, field, op string) (interface{}, error) {
+	fieldPath, err := EscapeFieldPath(field)
+	if err != nil {
+		return nil, err
+	}
+	target := map[string]interface{}{"field": map[string]interface{}{"fieldPath": fieldPath}}
+
+	aggregations := map[string]interface{}{"avg": map[string]interface{}{"avg": target}}
+	if op == "sum" {
+		aggregations["sum"] = map[string]interface{}{"sum": target}
+	}
+	results, err := runAggregation(ctx, projectID, databaseID, spec, aggregations)
+	if err != nil {
+		return nil, err
+	}
+	if results["avg"] == nil {
+		return nil, fmt.Errorf("%s of %s: %w", op, field, ErrNoNumericValues)
+	}
+	return results[op], nil
+}
+
+// runAggregation runs a runAggregationQuery over the documents matched by a
+// QuerySpec and returns the decoded result of each aggregation, keyed by
+// alias. aggregations maps each alias to its aggregation object.
+func runAggregation(ctx context.Context, projectID, databaseID string, spec QuerySpec, aggregations map[string]interface{}) (map[string]interface{}, error) {
+	structuredQuery, err := spec.StructuredQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryURL := spec.parentURL(ctx, projectID, databaseID) + ":runAggregationQuery"
+
+	var list []map[string]interface{}
+	for alias, aggregation := range aggregations {
+		entry := map[string]interface{}{"alias": alias}
+		for kind, value := range aggregation.(map[string]interface{}) {
+			entry[kind] = value
+		}
+		list = append(list, entry)
+	}
+
+	var result []struct {
+		Result *struct {
+			AggregateFields map[string]interface{} `json:"aggregateFields"`
+		} `json:"result"`
+	}
+	payload := map[string]interface{}{
+		"structuredAggregationQuery": map[string]interface{}{
+			"structuredQuery": structuredQuery,
+			"aggregations":    list,
+		},
+	}
+	addReadPayload(ctx, payload)
+	if err := firestoreRequest(ctx, "POST", queryURL, payload, &result); err != nil {
+		return nil, err
+	}
+
+	for _, res := range result {
+		if res.Result == nil {
+			continue
+		}
+		decoded, err := DecodeFields(res.Result.AggregateFields)
+		if err != nil {
+			return nil, fmt.Errorf("invalid aggregation result: %v", err)
+		}
+		for alias := range aggregations {
+			if _, ok := decoded[alias]; !ok {
+				return nil, fmt.Errorf("aggregation result is missing %s", alias)
+			}
+		}
+		return decoded, nil
+	}
+	return nil, fmt.Errorf("aggregation returned no result")
+}
+
+// FieldRange returns the smallest and largest values of a field across the
+// documents matched by a QuerySpec, each read with a one-document query
+// ordered by the field. Documents without the field, or holding null in it,
+// are not considered, and found is false when no document has a value. The
+// spec's orderBy and limits are replaced.
+func FieldRange(ctx context.Context, projectID, databaseID string, spec QuerySpec, field string) (min, max interface{}, found bool, err error) {
+	if min, found, err = fieldEdge(ctx, projectID, databaseID, spec, field, "ASCENDING"); err != nil || !found {
+		return nil, nil, false, err
+	}
+	if max, found, err = fieldEdge(ctx, projectID, databaseID, spec, field, "DESCENDING"); err != nil || !found {
+		return nil, nil, false, err
+	}
+	return min, max, true, nil
+}
+
+// FieldMax returns the largest value of a field across the documents matched
+// by a QuerySpec, read with a single one-document query, like FieldRange;
+// found is false when every document holds null.
+func FieldMax(ctx context.Context, projectID, databaseID string, spec QuerySpec, field string) (max interface{}, found bool, err error) {
+	return fieldEdge(ctx, projectID, databaseID, spec, field, "DESCENDING")
+}
+
+// fieldEdge returns the field's non-null value in the first document of the
+// spec ordered by the field in direction, with found false when no document
+// has one. Nulls sort first, so an ascending read starts after them; a
+// descending read only ends on null when every value is null.
+func fieldEdge(ctx context.Context, projectID, databaseID string, spec QuerySpec, field, direction string) (interface{}, bool, error) {
+	segments, err := splitFieldPath(field)
+	if err != nil {
+		return nil, false, err
+	}
+	spec.Select = []string{field}
+	spec.OrderBy = []Order{{Field: field, Direction: direction}}
+	spec.Limit = 1
+	spec.LimitToLast = 0
+	spec.skipNulls = direction == "ASCENDING"
+
+	documents, err := RunStructuredQuery(ctx, projectID, databaseID, spec)
+	if err != nil || len(documents) == 0 {
+		return nil, false, err
+	}
+	decoded, err := DecodeFields(documents[0].Fields)
+	if err != nil {
+		return nil, false, fmt.Errorf("document %s: %v", documents[0].ID, err)
+	}
+	var value interface{} = decoded
+	for _, name := range segments {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false, nil
+		}
+		if value, ok = m[name]; !ok {
+			return nil, false, nil
+		}
+	}
+	return value, value != nil, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CheckSortable returns an error when SORTABLE_FIELDS restricts the fields
+// collection may be sorted by and field is not one of them.
+func CheckSortable(ctx context.Context, collection, field string) error {
+	return checkAllowed(ctx, "SORTABLE_FIELDS", "sort", collection, field)
+}
+
+// CheckFilterable returns an error when FILTERABLE_FIELDS restricts the fields
+// collection may be filtered on and field is not one of them.
+func CheckFilterable(ctx context.Context, collection, field string) error {
+	return checkAllowed(ctx, "FILTERABLE_FIELDS", "filter", collection, field)
+}
+
+// CheckOperator returns an error when ALLOWED_FILTER_OPS, a comma-separated
+// list of operators such as "EQUAL,IN", restricts the filter operators a
+// structured query may use and op is not one of them. Unset allows them all.
+func CheckOperator(ctx context.Context, op string) error {
+	var allowed []string
+	for _, name := range strings.Split(setting(ctx, "ALLOWED_FILTER_OPS"), ",") {
+		if name = strings.ToUpper(strings.TrimSpace(name)); name != "" {
+			allowed = append(allowed, name)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, candidate := range allowed {
+		if candidate == op {
+			return nil
+		}
+	}
+	return fmt.Errorf("filter operator %q is not allowed; allowed operators: %s", op, strings.Join(allowed, ", "))
+}
+
+// checkAllowed enforces the allowlist in the named variable, formatted as
+// "collection=field,field;collection=field". "*" lists the fields allowed for
+// collections without an entry of their own. An unset variable, or a
+// collection with neither entry, allows every field.
+func checkAllowed(ctx context.Context, name, action, collection, field string) error {
+	allowed, ok := allowedFields(setting(ctx, name), collection)
+	if !ok {
+		return nil
+	}
+	for _, candidate := range allowed {
+		if candidate == field {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot %s %s by %q; allowed fields: %s", action, collection, field, strings.Join(allowed, ", "))
+}
+
+// allowedFields returns the fields listed for collection in an allowlist,
+// falling back to the "*" entry. ok is false when neither is listed.
+func allowedFields(raw, collection string) (fields []string, ok bool) {
+	var fallback []string
+	var hasFallback bool
+	for _, entry := range strings.Split(raw, ";") {
+		name, list, found := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			continue
+		}
+		var names []string
+		for _, field := range strings.Split(list, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				names = append(names, field)
+			}
+		}
+		switch name {
+		case collection:
+			return names, true
+		case "*":
+			fallback, hasFallback = names, true
+		}
+	}
+	return fallback, hasFallback
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"fmt"
+)
+
+// FirestoreBackend fetches documents from Firestore.
+type FirestoreBackend interface {
+	// FetchCollection lists every document in a collection. The returned bool
+	// reports whether the listing was truncated by MAX_PAGES.
+	FetchCollection(ctx context.Context, collection string) ([]FirestoreDocument, bool, error)
+	// FetchSubcollection returns the documents of every collection with the
+	// given ID, at any depth.
+	FetchSubcollection(ctx context.Context, subCollection string) ([]FirestoreDocument, error)
+}
+
+// RESTBackend implements FirestoreBackend with the Firestore REST API.
+type RESTBackend struct {
+	ProjectID  string
+	DatabaseID string
+}
+
+// FetchCollection lists a collection with the REST API, once per request memo.
+func (b RESTBackend) FetchCollection(ctx context.Context, collection string) ([]FirestoreDocument, bool, error) {
+	result, err := memoize(ctx, "collection:"+collection, func() (interface{}, error) {
+		documents, truncated, err := FetchDocumentsFromFirestore(ctx, b.ProjectID, b.DatabaseID, collection)
+		return cachedCollection{documents: documents, truncated: truncated}, err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	entry := result.(cachedCollection)
+	return copyDocuments(entry.documents), entry.truncated, nil
+}
+
+// FetchSubcollection queries a collection group with the REST API, once per
+// request memo.
+func (b RESTBackend) FetchSubcollection(ctx context.Context, subCollection string) ([]FirestoreDocument, error) {
+	result, err := memoize(ctx, "subcollection:"+subCollection, func() (interface{}, error) {
+		return FetchDocumentsFromFirestoreWithSubcollection(ctx, b.ProjectID, b.DatabaseID, subCollection)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copyDocuments(result.([]FirestoreDocument)), nil
+}
+
+// NewBackend returns the backend named by FIRESTORE_BACKEND ("rest" by default).
+func NewBackend(name, projectID, databaseID string) (FirestoreBackend, error) {
+	switch name {
+	case "", "rest":
+		return RESTBackend{ProjectID: projectID, DatabaseID: databaseID}, nil
+	}
+	return nil, fmt.Errorf("unknown FIRESTORE_BACKEND %q (expected rest)", name)
+}
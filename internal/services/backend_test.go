@@ -0,0 +1,27 @@
+package services
+
+import "testing"
+
+func TestNewBackend(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"", false},
+		{"rest", false},
+		{"native", true},
+		{"grpc", true},
+	}
+	for _, tt := range tests {
+		backend, err := NewBackend(tt.name, "project", "(default)")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewBackend(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr {
+			if rest, ok := backend.(RESTBackend); !ok || rest.ProjectID != "project" {
+				t.Errorf("NewBackend(%q) = %#v, want a RESTBackend for project", tt.name, backend)
+			}
+		}
+	}
+}
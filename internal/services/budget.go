@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+)
+
+// defaultMaxFirestoreCalls is the per-request call budget when MAX_FIRESTORE_CALLS is unset.
+const defaultMaxFirestoreCalls = 50
+
+// ErrBudgetExceeded is returned when a request has used up its Firestore call budget.
+var ErrBudgetExceeded = errors.New("firestore call budget exceeded")
+
+// budgetKey is the context key holding a *CallBudget.
+type budgetKey struct{}
+
+// CallBudget bounds the number of Firestore calls made while serving a request.
+type CallBudget struct {
+	limit    int64
+	used     atomic.Int64
+	exceeded atomic.Bool
+}
+
+// MaxFirestoreCalls returns the configured per-request call budget.
+func MaxFirestoreCalls(ctx context.Context) int {
+	value, err := strconv.Atoi(setting(ctx, "MAX_FIRESTORE_CALLS"))
+	if err != nil || value <= 0 {
+		return defaultMaxFirestoreCalls
+	}
+	return value
+}
+
+// WithCallBudget returns a context whose Firestore calls are limited to limit.
+func WithCallBudget(ctx context.Context, limit int) (context.Context, *CallBudget) {
+	budget := &CallBudget{limit: int64(limit)}
+	return context.WithValue(ctx, budgetKey{}, budget), budget
+}
+
+// Exceeded reports whether a call was refused because the budget ran out.
+func (b *CallBudget) Exceeded() bool {
+	return b.exceeded.Load()
+}
+
+// take reserves one call, reporting false once the budget is used up.
+func (b *CallBudget) take() bool {
+	if b.used.Add(1) > b.limit {
+		b.exceeded.Store(true)
+		return false
+	}
+	return true
+}
+
+// takeCall reserves a call from the context's budget, if it has one.
+func takeCall(ctx context.Context) error {
+	budget, _ := ctx.Value(budgetKey{}).(*CallBudget)
+	if budget != nil && !budget.take() {
+		return ErrBudgetExceeded
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"crossfire-grafana/internal/cache"
+	"crossfire-grafana/internal/config"
+	"crossfire-grafana/internal/metrics"
+)
+
+var (
+	cacheHits   = metrics.NewCounter("cache_hits_total", "Collection reads served from the cache.", "collection")
+	cacheMisses = metrics.NewCounter("cache_misses_total", "Cacheable collection reads fetched from Firestore.", "collection")
+	_           = metrics.NewGaugeFunc("cache_hit_ratio", "Share of cacheable collection reads served from the cache.", "collection", cacheHitRatio)
+)
+
+// cachedCollection is a cached FetchCollection result.
+type cachedCollection struct {
+	documents []FirestoreDocument
+	truncated bool
+}
+
+// CachedBackend wraps a FirestoreBackend with an in-memory cache whose TTL is
+// chosen per collection. A TTL of zero disables caching for that collection.
+type CachedBackend struct {
+	Backend FirestoreBackend
+	Cache   *cache.Cache
+	TTL     func(ctx context.Context, collection string) time.Duration
+}
+
+// FetchCollection serves a collection listing from the cache when fresh.
+func (b CachedBackend) FetchCollection(ctx context.Context, collection string) ([]FirestoreDocument, bool, error) {
+	ttl := b.ttl(ctx, collection)
+	key := "collection:" + collection
+	if ttl > 0 {
+		if cached, ok := b.Cache.Get(key); ok {
+			cacheHits.Inc(collectionLabel(ctx, collection))
+			entry := cached.(cachedCollection)
+			return copyDocuments(entry.documents), entry.truncated, nil
+		}
+		cacheMisses.Inc(collectionLabel(ctx, collection))
+	}
+
+	documents, truncated, err := b.Backend.FetchCollection(ctx, collection)
+	if err != nil {
+		if cached, ok := b.stale(ctx, key, ttl, err); ok {
+			entry := cached.(cachedCollection)
+			return copyDocuments(entry.documents), entry.truncated, nil
+		}
+		return nil, false, err
+	}
+	if ttl > 0 {
+		b.Cache.Set(key, cachedCollection{documents: copyDocuments(documents), truncated: truncated}, ttl, staleMaxAge(ctx))
+	}
+	return documents, truncated, nil
+}
+
+// FetchSubcollection serves a collection-group query from the cache when fresh.
+func (b CachedBackend) FetchSubcollection(ctx context.Context, subCollection string) ([]FirestoreDocument, error) {
+	ttl := b.ttl(ctx, subCollection)
+	key := "subcollection:" + subCollection
+	if ttl > 0 {
+		if cached, ok := b.Cache.Get(key); ok {
+			cacheHits.Inc(collectionLabel(ctx, subCollection))
+			return copyDocuments(cached.([]FirestoreDocument)), nil
+		}
+		cacheMisses.Inc(collectionLabel(ctx, subCollection))
+	}
+
+	documents, err := b.Backend.FetchSubcollection(ctx, subCollection)
+	if err != nil {
+		if cached, ok := b.stale(ctx, key, ttl, err); ok {
+			return copyDocuments(cached.([]FirestoreDocument)), nil
+		}
+		return nil, err
+	}
+	if ttl > 0 {
+		b.Cache.Set(key, copyDocuments(documents), ttl, staleMaxAge(ctx))
+	}
+	return documents, nil
+}
+
+// Purge drops the cached listings of collection, or every cached entry when
+// collection is empty, and returns the number of entries removed. Stale
+// copies go too, so the next read always reaches Firestore.
+func (b CachedBackend) Purge(collection string) int {
+	if collection == "" {
+		return b.Cache.Clear()
+	}
+	return b.Cache.Delete("collection:"+collection, "subcollection:"+collection)
+}
+
+// stale returns the last cached value for key when a read failed and it is
+// within STALE_MAX_AGE, flagging the request as served stale.
+func (b CachedBackend) stale(ctx context.Context, key string, ttl time.Duration, err error) (interface{}, bool) {
+	maxAge := staleMaxAge(ctx)
+	if ttl <= 0 || maxAge <= 0 {
+		return nil, false
+	}
+	cached, ok := b.Cache.GetStale(key, maxAge)
+	if !ok {
+		return nil, false
+	}
+	log.Printf("Warning: serving stale %s after Firestore error: %v", key, err)
+	markStale(ctx)
+	return cached, true
+}
+
+// ttl returns the cache TTL for a read. Reads pinned to a snapshot bypass the
+// cache, as do reads with a credential profile, whose account may not be
+// allowed to see what the default credentials cached, and explained reads,
+// which must reach Firestore to be analyzed.
+func (b CachedBackend) ttl(ctx context.Context, collection string) time.Duration {
+	if hasReadConsistency(ctx) || profileFrom(ctx) != "" || queryExplainFrom(ctx) != nil {
+		return 0
+	}
+	return b.TTL(ctx, collection)
+}
+
+// collectionLabel returns the metrics label for a collection: its name when
+// the field config lists it, or "other". Collection names come from request
+// parameters, so labelling them all would grow the metrics without bound.
+func collectionLabel(ctx context.Context, collection string) string {
+	if _, ok := config.FromContext(ctx).Fields.Collections[collection]; ok {
+		return collection
+	}
+	return "other"
+}
+
+// copyDocuments copies a document slice so callers can reorder it without
+// affecting the cached entry.
+func copyDocuments(docs []FirestoreDocument) []FirestoreDocument {
+	return append([]FirestoreDocument(nil), docs...)
+}
+
+// cacheHitRatio computes hits / (hits + misses) for each collection.
+func cacheHitRatio() map[string]float64 {
+	hits, misses := cacheHits.Snapshot(), cacheMisses.Snapshot()
+	ratios := make(map[string]float64)
+	for collection, missed := range misses {
+		ratios[collection] = hits[collection] / (hits[collection] + missed)
+	}
+	for collection := range hits {
+		if _, ok := ratios[collection]; !ok {
+			ratios[collection] = 1
+		}
+	}
+	return ratios
+}
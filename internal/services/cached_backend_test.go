@@ -0,0 +1,20 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"crossfire-grafana/internal/config"
+)
+
+func TestCollectionLabel(t *testing.T) {
+	rt := &config.Runtime{Fields: &config.FieldConfig{Collections: map[string]config.CollectionConfig{"restaurants": {}}}}
+	ctx := config.WithRuntime(context.Background(), rt)
+
+	if got := collectionLabel(ctx, "restaurants"); got != "restaurants" {
+		t.Errorf("collectionLabel(restaurants) = %q, want restaurants", got)
+	}
+	if got := collectionLabel(ctx, "I001"); got != "other" {
+		t.Errorf("collectionLabel(I001) = %q, want other", got)
+	}
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"crossfire-grafana/internal/config"
+	"crossfire-grafana/internal/version"
+	"golang.org/x/sync/semaphore"
+)
+
+// firestoreBaseURL is the root of the Firestore REST API.
+const firestoreBaseURL = "https://firestore.googleapis.com/v1"
+
+// apiBaseURL returns the Firestore REST root, pointing at the emulator when
+// FIRESTORE_EMULATOR_HOST is set.
+func apiBaseURL() string {
+	if emulatorHost != "" {
+		return "http://" + emulatorHost + "/v1"
+	}
+	return firestoreBaseURL
+}
+
+var (
+	// emulatorHost is the configured FIRESTORE_EMULATOR_HOST, if any
+	emulatorHost string
+	limiter      *semaphore.Weighted
+	sharedClient = newHTTPClient(config.Config{})
+)
+
+// Configure applies the startup settings of cfg to Firestore calls: the
+// emulator host, the limit on concurrent calls and the shared HTTP client's
+// proxy and connection settings. It is called once, before the server starts.
+func Configure(cfg config.Config) {
+	emulatorHost = cfg.EmulatorHost
+	limiter = nil
+	if cfg.MaxConcurrency > 0 {
+		limiter = semaphore.NewWeighted(int64(cfg.MaxConcurrency))
+	}
+	sharedClient = newHTTPClient(cfg)
+}
+
+// callLimiter returns the process-wide limiter on concurrent Firestore calls,
+// sized by FIRESTORE_MAX_CONCURRENCY, or nil when it is unset (no limit).
+func callLimiter() *semaphore.Weighted {
+	return limiter
+}
+
+// httpClient returns the shared HTTP client used for Firestore calls.
+func httpClient() *http.Client {
+	return sharedClient
+}
+
+// newHTTPClient builds an HTTP client with a proxy-aware transport that
+// honors HTTP_PROXY/HTTPS_PROXY/NO_PROXY, or FIRESTORE_PROXY_URL when set.
+// FIRESTORE_IDLE_CONN_TIMEOUT closes pooled connections idle for longer (90s
+// by default) and FIRESTORE_KEEPALIVE sets the TCP keep-alive probe interval
+// (30s by default), so connections silently dropped by a NAT or firewall are
+// not reused for the first request after a quiet period.
+func newHTTPClient(cfg config.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.KeepAlive > 0 {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: cfg.KeepAlive}
+		transport.DialContext = dialer.DialContext
+	}
+	if cfg.ProxyURL != nil {
+		transport.Proxy = http.ProxyURL(cfg.ProxyURL)
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+// userAgent returns the User-Agent sent on Firestore requests:
+// FIRESTORE_USER_AGENT, or crossfire-grafana/<version>.
+func userAgent(ctx context.Context) string {
+	if agent := setting(ctx, "FIRESTORE_USER_AGENT"); agent != "" {
+		return agent
+	}
+	return "crossfire-grafana/" + version.Version
+}
+
+// documentsURL returns the documents root for a project and database, or for
+// the database of the credential profile selected on ctx (see ProfileDatabase).
+func documentsURL(ctx context.Context, projectID, databaseID string) string {
+	projectID, databaseID = ProfileDatabase(ctx, projectID, databaseID)
+	return fmt.Sprintf("%s/projects/%s/databases/%s/documents", apiBaseURL(), projectID, databaseID)
+}
+
+// firestoreRequest sends an authenticated request to Firestore and decodes the
+// JSON response into out. The request is cancelled with ctx, and recorded in
+// the context's DebugTrace and timed by its FetchTimer if it has them.
+func firestoreRequest(ctx context.Context, method, requestURL string, payload interface{}, out interface{}) error {
+	return firestoreStream(ctx, method, requestURL, payload, func(dec *json.Decoder) error {
+		return dec.Decode(out)
+	})
+}
+
+// firestoreStream sends an authenticated request to Firestore like
+// firestoreRequest, but hands a successful response to decode as a stream so
+// large bodies need not be held in memory at once.
+func firestoreStream(ctx context.Context, method, requestURL string, payload interface{}, decode func(dec *json.Decoder) error) error {
+	var body io.Reader
+	var encoded []byte
+	if payload != nil {
+		var err error
+		encoded, err = json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	if err := takeCall(ctx); err != nil {
+		return err
+	}
+
+	// Hold a slot of the global limiter until the response has been consumed
+	if limiter := callLimiter(); limiter != nil {
+		if err := limiter.Acquire(ctx, 1); err != nil {
+			return fmt.Errorf("waiting for a Firestore call slot: %w", err)
+		}
+		defer limiter.Release(1)
+	}
+
+	defer startFetch(ctx)()
+
+	trace := debugTraceFrom(ctx)
+	started := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+
+	token, err := GetFirestoreAccessToken(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", userAgent(ctx))
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	setRequestParams(ctx, req, requestURL)
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	reader, err := responseBody(resp)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// Keep the raw response for the debug trace before decoding it
+	if trace != nil {
+		raw, err := io.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %v", err)
+		}
+		trace.record(method, requestURL, encoded, resp.StatusCode, raw, time.Since(started))
+		reader = io.NopCloser(bytes.NewReader(raw))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(reader)
+		apiErr := newAPIError(resp.StatusCode, requestURL, raw)
+		if apiErr.Status == "" {
+			apiErr.Status = http.StatusText(resp.StatusCode)
+		}
+		apiErr.RetryAfter = resp.Header.Get("Retry-After")
+		if apiErr.Is(ErrQuotaExceeded) {
+			quotaExceeded.Inc(apiErr.Database)
+		}
+		return apiErr
+	}
+
+	// Keep numbers as json.Number so integer values are never rounded through float64
+	dec := json.NewDecoder(reader)
+	dec.UseNumber()
+	if err := decode(dec); err != nil {
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+	return nil
+}
+
+// resourcePattern extracts the project and database IDs from a Firestore request URL.
+var resourcePattern = regexp.MustCompile(`/projects/([^/]+)/databases/([^/:]+)`)
+
+// setRequestParams adds the Google API request headers derived from the call:
+// X-Goog-Request-Params routing the call to its database, and, when ctx has a
+// deadline, X-Server-Timeout with the seconds left so Firestore abandons work
+// the caller will no longer wait for.
+func setRequestParams(ctx context.Context, req *http.Request, requestURL string) {
+	if match := resourcePattern.FindStringSubmatch(requestURL); match != nil {
+		params := url.Values{}
+		params.Set("project_id", match[1])
+		params.Set("database_id", match[2])
+		req.Header.Set("X-Goog-Request-Params", params.Encode())
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining > 0 {
+			req.Header.Set("X-Server-Timeout", strconv.FormatFloat(remaining.Seconds(), 'f', 3, 64))
+		}
+	}
+}
+
+// responseBody returns a reader over the decoded response body. The transport
+// only decompresses gzip transparently when it added Accept-Encoding itself, so
+// a response still marked as gzip-encoded is decompressed here.
+func responseBody(resp *http.Response) (io.ReadCloser, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return io.NopCloser(resp.Body), nil
+	}
+
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress response: %v", err)
+	}
+	return reader, nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ApplyTypeHints coerces string values in a decoded document to the types named
+// by hints. Values that can't be coerced are left unchanged and logged through
+// sampler (every failure when it is nil).
+func ApplyTypeHints(decoded map[string]interface{}, hints map[string]string, sampler *LogSampler) {
+	for path, kind := range hints {
+		value, ok := LookupPath(decoded, path)
+		if !ok {
+			continue
+		}
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		coerced, err := coerceString(s, kind)
+		if err != nil {
+			sampler.Printf("Warning: could not coerce %s=%q to %s: %v", path, s, kind, err)
+			continue
+		}
+		setPath(decoded, path, coerced)
+	}
+}
+
+// coerceString converts a string to the named type.
+func coerceString(s, kind string) (interface{}, error) {
+	s = strings.TrimSpace(s)
+	switch kind {
+	case "number":
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+		return strconv.ParseFloat(s, 64)
+	case "bool":
+		return strconv.ParseBool(s)
+	case "time":
+		return time.Parse(time.RFC3339Nano, s)
+	}
+	return nil, fmt.Errorf("unknown type %q", kind)
+}
+
+// setPath replaces the value at an existing dot-notation path in a decoded document.
+func setPath(decoded map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	current := decoded
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]interface{})
+		if !ok {
+			return
+		}
+		current = next
+	}
+	current[parts[len(parts)-1]] = value
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ListDocumentNames lists the full names of every document in a collection
+// without fetching field data. Missing parent documents that only hold
+// subcollections are included.
+func ListDocumentNames(ctx context.Context, projectID, databaseID, collection string) ([]string, error) {
+	var names []string
+	var nextPageToken string
+
+	for page := 1; page <= maxPages(ctx); page++ {
+		query := url.Values{}
+		query.Set("showMissing", "true")
+		query.Set("mask.fieldPaths", "__name__")
+		if nextPageToken != "" {
+			query.Set("pageToken", nextPageToken)
+		}
+		addReadQuery(ctx, query)
+		requestURL := fmt.Sprintf("%s/%s?%s", documentsURL(ctx, projectID, databaseID), collection, query.Encode())
+
+		var result struct {
+			Documents     []FirestoreDocument `json:"documents"`
+			NextPageToken string              `json:"nextPageToken"`
+		}
+		if err := firestoreRequest(ctx, "GET", requestURL, nil, &result); err != nil {
+			if errors.Is(err, ErrBudgetExceeded) && page > 1 {
+				break
+			}
+			return nil, err
+		}
+
+		for _, doc := range result.Documents {
+			names = append(names, doc.Name)
+		}
+		if result.NextPageToken == "" {
+			break
+		}
+		nextPageToken = result.NextPageToken
+	}
+
+	return names, nil
+}
+
+// idsPageSize is the number of IDs ListDocumentIDs reads per query.
+const idsPageSize = 1000
+
+// ListDocumentIDs returns the IDs of the documents in a collection, or with
+// allDescendants in every collection with that ID. The queries select only
+// __name__, so no field data is transferred. IDs are read idsPageSize at a
+// time up to MAX_PAGES pages; the returned bool reports whether the listing
+// was truncated because the page limit or call budget was reached.
+func ListDocumentIDs(ctx context.Context, projectID, databaseID, collection string, allDescendants bool) ([]string, bool, error) {
+	spec := QuerySpec{Collection: collection, AllDescendants: allDescendants, Select: []string{"__name__"}, Limit: idsPageSize}
+	var ids []string
+	for page := 1; page <= maxPages(ctx); page++ {
+		documents, err := RunStructuredQuery(ctx, projectID, databaseID, spec)
+		if err != nil {
+			if errors.Is(err, ErrBudgetExceeded) && page > 1 {
+				return ids, true, nil
+			}
+			return nil, false, err
+		}
+		for _, doc := range documents {
+			ids = append(ids, doc.ID)
+		}
+		if len(documents) < idsPageSize {
+			return ids, false, nil
+		}
+		spec.afterName = documents[len(documents)-1].Name
+	}
+	return ids, true, nil
+}
+
+// ListSubcollectionIDs lists the IDs of the subcollections directly under a document.
+func ListSubcollectionIDs(ctx context.Context, projectID, databaseID, documentPath string) ([]string, error) {
+	requestURL := fmt.Sprintf("%s/%s:listCollectionIds", documentsURL(ctx, projectID, databaseID), documentPath)
+
+	var ids []string
+	var nextPageToken string
+
+	for page := 1; page <= maxPages(ctx); page++ {
+		payload := map[string]interface{}{}
+		if nextPageToken != "" {
+			payload["pageToken"] = nextPageToken
+		}
+		// listCollectionIds supports readTime but not transactions
+		if readTime := readTimeFrom(ctx); readTime != "" {
+			payload["readTime"] = readTime
+		}
+
+		var result struct {
+			CollectionIDs []string `json:"collectionIds"`
+			NextPageToken string   `json:"nextPageToken"`
+		}
+		if err := firestoreRequest(ctx, "POST", requestURL, payload, &result); err != nil {
+			if errors.Is(err, ErrBudgetExceeded) && page > 1 {
+				break
+			}
+			return nil, err
+		}
+
+		ids = append(ids, result.CollectionIDs...)
+		if result.NextPageToken == "" {
+			break
+		}
+		nextPageToken = result.NextPageToken
+	}
+
+	return ids, nil
+}
+
+// DistinctSubcollectionIDs returns the sorted, distinct subcollection IDs under
+// a parent. A document path is listed directly; a collection path lists the
+// subcollections of each of its documents. Listing stops early, returning the
+// IDs found so far, once the request's call budget runs out.
+func DistinctSubcollectionIDs(ctx context.Context, projectID, databaseID, parent string) ([]string, error) {
+	parents, err := SubcollectionParents(ctx, projectID, databaseID, parent)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(parents))
+	for id := range parents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// SubcollectionParents maps each subcollection ID under a parent to the paths
+// of the documents holding a subcollection of that ID, relative to the
+// documents root. Parents are listed as by DistinctSubcollectionIDs.
+//
+// Subcollections are found with listCollectionIds, one call per parent
+// document: a collection-group query needs the collection ID being looked
+// for, and a kindless allDescendants query would return every document below
+// the parent (every order) to learn a few IDs.
+func SubcollectionParents(ctx context.Context, projectID, databaseID, parent string) (map[string][]string, error) {
+	parent = strings.Trim(parent, "/")
+	documentPaths := []string{parent}
+
+	// An odd number of segments names a collection rather than a document
+	if len(strings.Split(parent, "/"))%2 == 1 {
+		names, err := ListDocumentNames(ctx, projectID, databaseID, parent)
+		if err != nil {
+			return nil, err
+		}
+		documentPaths = documentPaths[:0]
+		for _, name := range names {
+			// names are under the profile's database when one is selected
+			_, path, _ := strings.Cut(name, "/documents/")
+			documentPaths = append(documentPaths, path)
+		}
+	}
+
+	parents := make(map[string][]string)
+	for _, path := range documentPaths {
+		subcollections, err := ListSubcollectionIDs(ctx, projectID, databaseID, path)
+		if errors.Is(err, ErrBudgetExceeded) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range subcollections {
+			parents[id] = append(parents[id], path)
+		}
+	}
+	return parents, nil
+}
+
+// Ping checks that the database is reachable by listing at most one root
+// collection ID.
+func Ping(ctx context.Context, projectID, databaseID string) error {
+	requestURL := documentsURL(ctx, projectID, databaseID) + ":listCollectionIds"
+	var result struct {
+		CollectionIDs []string `json:"collectionIds"`
+	}
+	return firestoreRequest(ctx, "POST", requestURL, map[string]interface{}{"pageSize": 1}, &result)
+}
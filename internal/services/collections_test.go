@@ -0,0 +1,102 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDistinctSubcollectionIDs(t *testing.T) {
+	subcollections := map[string]string{
+		"latest-orders/NANALL":  `["S2","S1"]`,
+		"latest-orders/NANALL2": `["S1","S3"]`,
+		"latest-orders/EMPTY":   `[]`,
+	}
+	fakeFirestore(t, func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path[strings.Index(r.URL.Path, "/documents/")+len("/documents/"):]
+		if document, ok := strings.CutSuffix(path, ":listCollectionIds"); ok {
+			fmt.Fprintf(w, `{"collectionIds": %s}`, subcollections[document])
+			return
+		}
+		fmt.Fprint(w, `{"documents": [
+			{"name": "projects/p/databases/d/documents/latest-orders/NANALL"},
+			{"name": "projects/p/databases/d/documents/latest-orders/NANALL2"},
+			{"name": "projects/p/databases/d/documents/latest-orders/EMPTY"}
+		]}`)
+	})
+
+	tests := []struct {
+		parent string
+		want   []string
+	}{
+		{"latest-orders", []string{"S1", "S2", "S3"}},
+		{"latest-orders/NANALL", []string{"S1", "S2"}},
+		{"latest-orders/EMPTY", []string{}},
+	}
+	for _, tt := range tests {
+		ids, err := DistinctSubcollectionIDs(withSettings(nil), "p", "d", tt.parent)
+		if err != nil {
+			t.Fatalf("DistinctSubcollectionIDs(%q): %v", tt.parent, err)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("DistinctSubcollectionIDs(%q) = %q, want %q", tt.parent, ids, tt.want)
+		}
+	}
+}
+
+func TestListDocumentIDsPages(t *testing.T) {
+	const total = 2500
+	var queries int
+	fakeFirestore(t, func(w http.ResponseWriter, r *http.Request) {
+		queries++
+		var payload struct {
+			StructuredQuery struct {
+				StartAt struct {
+					Values []struct {
+						ReferenceValue string `json:"referenceValue"`
+					} `json:"values"`
+				} `json:"startAt"`
+				Limit int `json:"limit"`
+			} `json:"structuredQuery"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		start := 0
+		if values := payload.StructuredQuery.StartAt.Values; len(values) == 1 {
+			fmt.Sscanf(values[0].ReferenceValue, "projects/p/databases/d/documents/orders/%05d", &start)
+			start++
+		}
+		var entries []string
+		for i := start; i < total && i < start+payload.StructuredQuery.Limit; i++ {
+			entries = append(entries, fmt.Sprintf(`{"document": {"name": "projects/p/databases/d/documents/orders/%05d"}}`, i))
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(entries, ","))
+	})
+
+	tests := []struct {
+		maxPages      string
+		wantIDs       int
+		wantQueries   int
+		wantTruncated bool
+	}{
+		{"", total, 3, false},
+		{"3", total, 3, false},
+		{"2", 2 * idsPageSize, 2, true},
+	}
+	for _, tt := range tests {
+		queries = 0
+		ids, truncated, err := ListDocumentIDs(withSettings(map[string]string{"MAX_PAGES": tt.maxPages}), "p", "d", "orders", false)
+		if err != nil {
+			t.Fatalf("MAX_PAGES=%q: %v", tt.maxPages, err)
+		}
+		if len(ids) != tt.wantIDs || truncated != tt.wantTruncated || queries != tt.wantQueries {
+			t.Errorf("MAX_PAGES=%q: %d IDs, truncated %v in %d queries; want %d, %v in %d",
+				tt.maxPages, len(ids), truncated, queries, tt.wantIDs, tt.wantTruncated, tt.wantQueries)
+		}
+		if len(ids) > 0 && (ids[0] != "00000" || ids[len(ids)-1] != fmt.Sprintf("%05d", tt.wantIDs-1)) {
+			t.Errorf("MAX_PAGES=%q: IDs run %s to %s", tt.maxPages, ids[0], ids[len(ids)-1])
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// defaultReadTimeRetention is how far back Firestore serves reads without
+// point-in-time recovery enabled.
+const defaultReadTimeRetention = time.Hour
+
+// transactionKey is the context key holding a read-only transaction ID.
+type transactionKey struct{}
+
+// WithTransaction returns a context whose reads run in the given transaction.
+func WithTransaction(ctx context.Context, transaction string) context.Context {
+	return context.WithValue(ctx, transactionKey{}, transaction)
+}
+
+// transactionFrom returns the transaction attached to ctx, if any.
+func transactionFrom(ctx context.Context) string {
+	transaction, _ := ctx.Value(transactionKey{}).(string)
+	return transaction
+}
+
+// readTimeKey is the context key holding a snapshot read time.
+type readTimeKey struct{}
+
+// WithReadTime returns a context whose reads observe the database as of readTime.
+func WithReadTime(ctx context.Context, readTime time.Time) context.Context {
+	return context.WithValue(ctx, readTimeKey{}, readTime)
+}
+
+// readTimeFrom returns the read time attached to ctx, if any, formatted for Firestore.
+func readTimeFrom(ctx context.Context) string {
+	readTime, ok := ctx.Value(readTimeKey{}).(time.Time)
+	if !ok {
+		return ""
+	}
+	return readTime.UTC().Format(time.RFC3339Nano)
+}
+
+// ReadTimeRetention returns how old a readTime may be: READ_TIME_RETENTION
+// (e.g. 168h with point-in-time recovery enabled), or one hour.
+func ReadTimeRetention(ctx context.Context) time.Duration {
+	if retention, err := time.ParseDuration(setting(ctx, "READ_TIME_RETENTION")); err == nil && retention > 0 {
+		return retention
+	}
+	return defaultReadTimeRetention
+}
+
+// ValidateReadTime checks that Firestore can serve a read at readTime: not in
+// the future, within the retention window, and on a whole minute when older
+// than one hour.
+func ValidateReadTime(ctx context.Context, readTime, now time.Time) error {
+	age := now.Sub(readTime)
+	switch {
+	case age < 0:
+		return fmt.Errorf("readTime %s is in the future", readTime.Format(time.RFC3339))
+	case age > ReadTimeRetention(ctx):
+		return fmt.Errorf("readTime %s is older than the %s retention window", readTime.Format(time.RFC3339), ReadTimeRetention(ctx))
+	case age > time.Hour && !readTime.Equal(readTime.Truncate(time.Minute)):
+		return fmt.Errorf("readTime %s is older than one hour and must be a whole minute", readTime.Format(time.RFC3339Nano))
+	}
+	return nil
+}
+
+// hasReadConsistency reports whether reads in ctx are pinned to a snapshot, in
+// which case they must not be served from a cache.
+func hasReadConsistency(ctx context.Context) bool {
+	return transactionFrom(ctx) != "" || readTimeFrom(ctx) != ""
+}
+
+// addReadQuery adds the context's read consistency to GET query parameters.
+func addReadQuery(ctx context.Context, query url.Values) {
+	if transaction := transactionFrom(ctx); transaction != "" {
+		query.Set("transaction", transaction)
+	} else if readTime := readTimeFrom(ctx); readTime != "" {
+		query.Set("readTime", readTime)
+	}
+}
+
+// addReadPayload adds the context's read consistency to a runQuery payload.
+func addReadPayload(ctx context.Context, payload map[string]interface{}) {
+	if transaction := transactionFrom(ctx); transaction != "" {
+		payload["transaction"] = transaction
+	} else if readTime := readTimeFrom(ctx); readTime != "" {
+		payload["readTime"] = readTime
+	}
+}
+
+// BeginReadOnlyTransaction starts a read-only transaction so that several reads
+// observe the same snapshot, taken at the context's read time if it has one.
+func BeginReadOnlyTransaction(ctx context.Context, projectID, databaseID string) (string, error) {
+	requestURL := documentsURL(ctx, projectID, databaseID) + ":beginTransaction"
+	readOnly := map[string]interface{}{}
+	if readTime := readTimeFrom(ctx); readTime != "" {
+		readOnly["readTime"] = readTime
+	}
+	payload := map[string]interface{}{
+		"options": map[string]interface{}{"readOnly": readOnly},
+	}
+
+	var result struct {
+		Transaction string `json:"transaction"`
+	}
+	if err := firestoreRequest(ctx, "POST", requestURL, payload, &result); err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	return result.Transaction, nil
+}
+
+// RollbackTransaction releases a transaction started by BeginReadOnlyTransaction.
+func RollbackTransaction(ctx context.Context, projectID, databaseID, transaction string) error {
+	requestURL := documentsURL(ctx, projectID, databaseID) + ":rollback"
+	payload := map[string]interface{}{"transaction": transaction}
+
+	var result struct{}
+	if err := firestoreRequest(ctx, "POST", requestURL, payload, &result); err != nil {
+		return fmt.Errorf("failed to roll back transaction: %v", err)
+	}
+	return nil
+}
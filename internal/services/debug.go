@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// debugTraceKey is the context key holding a *DebugTrace.
+type debugTraceKey struct{}
+
+// DebugCall records one Firestore request and its raw response.
+type DebugCall struct {
+	Method     string          `json:"method"`
+	URL        string          `json:"url"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	Status     int             `json:"status"`
+	Response   interface{}     `json:"response"`
+	DurationMs int64           `json:"durationMs"`
+}
+
+// DebugTrace collects the Firestore calls made while serving a request.
+type DebugTrace struct {
+	mu    sync.Mutex
+	calls []DebugCall
+}
+
+// WithDebugTrace returns a context that records Firestore calls into a new trace.
+func WithDebugTrace(ctx context.Context) (context.Context, *DebugTrace) {
+	trace := &DebugTrace{}
+	return context.WithValue(ctx, debugTraceKey{}, trace), trace
+}
+
+// debugTraceFrom returns the trace attached to ctx, if any.
+func debugTraceFrom(ctx context.Context) *DebugTrace {
+	trace, _ := ctx.Value(debugTraceKey{}).(*DebugTrace)
+	return trace
+}
+
+// Calls returns the recorded calls in the order they completed.
+func (t *DebugTrace) Calls() []DebugCall {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]DebugCall(nil), t.calls...)
+}
+
+// record adds a completed call to the trace.
+func (t *DebugTrace) record(method, url string, request []byte, status int, response []byte, duration time.Duration) {
+	call := DebugCall{
+		Method:     method,
+		URL:        url,
+		Status:     status,
+		DurationMs: duration.Milliseconds(),
+	}
+	if len(request) > 0 {
+		call.Request = request
+	}
+	// Keep valid JSON structured, and anything else as text
+	if json.Valid(response) {
+		call.Response = json.RawMessage(response)
+	} else {
+		call.Response = string(response)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls = append(t.calls, call)
+}
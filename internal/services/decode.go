@@ -0,0 +1,179 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseFirestoreValue converts a Firestore typed value (e.g. {"stringValue": "x"})
+// into a plain Go value. integerValue decodes to int64 and doubleValue to
+// float64; responses are read with json.Decoder.UseNumber, so a numeric
+// integerValue (rather than Firestore's usual string) arrives as a json.Number
+// and keeps every digit instead of being rounded through float64.
+func ParseFirestoreValue(value interface{}) (interface{}, error) {
+	typed, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a Firestore value object, got %T", value)
+	}
+
+	for kind, raw := range typed {
+		switch kind {
+		case "nullValue":
+			return nil, nil
+		case "booleanValue":
+			b, ok := raw.(bool)
+			if !ok {
+				return nil, fmt.Errorf("booleanValue is not a bool: %v", raw)
+			}
+			return b, nil
+		case "integerValue":
+			// Firestore sends int64 values as strings to preserve precision
+			switch v := raw.(type) {
+			case string:
+				n, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid integerValue %q: %v", v, err)
+				}
+				return n, nil
+			case json.Number:
+				n, err := v.Int64()
+				if err != nil {
+					return nil, fmt.Errorf("invalid integerValue %s: %v", v, err)
+				}
+				return n, nil
+			case float64:
+				return int64(v), nil
+			}
+			return nil, fmt.Errorf("integerValue has unexpected type %T", raw)
+		case "doubleValue":
+			switch v := raw.(type) {
+			case float64:
+				return v, nil
+			case json.Number:
+				f, err := v.Float64()
+				if err != nil {
+					return nil, fmt.Errorf("invalid doubleValue %s: %v", v, err)
+				}
+				return f, nil
+			case string:
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid doubleValue %q: %v", v, err)
+				}
+				return f, nil
+			}
+			return nil, fmt.Errorf("doubleValue has unexpected type %T", raw)
+		case "timestampValue":
+			s, ok := raw.(string)
+			if !ok {
+				return nil, fmt.Errorf("timestampValue is not a string: %v", raw)
+			}
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestampValue %q: %v", s, err)
+			}
+			return t, nil
+		case "stringValue", "bytesValue", "referenceValue":
+			s, ok := raw.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s is not a string: %v", kind, raw)
+			}
+			return s, nil
+		case "geoPointValue":
+			point, ok := raw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("geoPointValue is not an object: %v", raw)
+			}
+			return map[string]interface{}{
+				"latitude":  point["latitude"],
+				"longitude": point["longitude"],
+			}, nil
+		case "arrayValue":
+			array, ok := raw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("arrayValue is not an object: %v", raw)
+			}
+			values, _ := array["values"].([]interface{})
+			decoded := make([]interface{}, 0, len(values))
+			for _, v := range values {
+				d, err := ParseFirestoreValue(v)
+				if err != nil {
+					return nil, err
+				}
+				decoded = append(decoded, d)
+			}
+			return decoded, nil
+		case "mapValue":
+			m, ok := raw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("mapValue is not an object: %v", raw)
+			}
+			fields, _ := m["fields"].(map[string]interface{})
+			return DecodeFields(fields)
+		}
+	}
+
+	return nil, fmt.Errorf("unknown Firestore value: %v", value)
+}
+
+// DecodeFields converts a Firestore document's fields into plain Go values.
+func DecodeFields(fields map[string]interface{}) (map[string]interface{}, error) {
+	decoded := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		d, err := ParseFirestoreValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", key, err)
+		}
+		decoded[key] = d
+	}
+	return decoded, nil
+}
+
+// LookupPath returns the value at a dot-notation path in a decoded document.
+func LookupPath(decoded map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = decoded
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// MaxSafeInteger is the largest integer a JSON consumer using float64 numbers
+// (such as JavaScript) can represent exactly.
+const MaxSafeInteger = 1<<53 - 1
+
+// StringifyLargeInts replaces int64 values outside ±MaxSafeInteger with their
+// decimal string, at any depth of a decoded document, so consumers that parse
+// JSON numbers as floats don't silently lose precision.
+func StringifyLargeInts(decoded map[string]interface{}) {
+	for key, value := range decoded {
+		decoded[key] = stringifyLargeInt(value)
+	}
+}
+
+// stringifyLargeInt applies StringifyLargeInts to a single decoded value.
+func stringifyLargeInt(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int64:
+		if v > MaxSafeInteger || v < -MaxSafeInteger {
+			return strconv.FormatInt(v, 10)
+		}
+	case map[string]interface{}:
+		StringifyLargeInts(v)
+	case []interface{}:
+		for i, item := range v {
+			v[i] = stringifyLargeInt(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFirestoreValue(t *testing.T) {
+	timestamp := time.Date(2024, 12, 16, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{"null", map[string]interface{}{"nullValue": nil}, nil},
+		{"bool", map[string]interface{}{"booleanValue": true}, true},
+		{"integer string", map[string]interface{}{"integerValue": "9007199254740993"}, int64(9007199254740993)},
+		{"integer number", map[string]interface{}{"integerValue": json.Number("42")}, int64(42)},
+		{"double", map[string]interface{}{"doubleValue": 1.5}, 1.5},
+		{"double string", map[string]interface{}{"doubleValue": "1.25"}, 1.25},
+		{"timestamp", map[string]interface{}{"timestampValue": "2024-12-16T09:30:00Z"}, timestamp},
+		{"string", map[string]interface{}{"stringValue": "NSW"}, "NSW"},
+		{"reference", map[string]interface{}{"referenceValue": "projects/p/databases/d/documents/a/b"}, "projects/p/databases/d/documents/a/b"},
+		{"geo point", map[string]interface{}{"geoPointValue": map[string]interface{}{"latitude": -33.8, "longitude": 151.2}}, map[string]interface{}{"latitude": -33.8, "longitude": 151.2}},
+		{"empty array", map[string]interface{}{"arrayValue": map[string]interface{}{}}, []interface{}{}},
+		{"array", map[string]interface{}{"arrayValue": map[string]interface{}{"values": []interface{}{
+			map[string]interface{}{"stringValue": "a"},
+			map[string]interface{}{"integerValue": "1"},
+		}}}, []interface{}{"a", int64(1)}},
+		{"map", map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+			"state": map[string]interface{}{"stringValue": "NSW"},
+		}}}, map[string]interface{}{"state": "NSW"}},
+		{"empty map", map[string]interface{}{"mapValue": map[string]interface{}{}}, map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFirestoreValue(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFirestoreValue(%v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFirestoreValueRejectsMalformedValues(t *testing.T) {
+	for _, value := range []interface{}{
+		"plain",
+		map[string]interface{}{},
+		map[string]interface{}{"unknownValue": 1},
+		map[string]interface{}{"booleanValue": "true"},
+		map[string]interface{}{"integerValue": "1.5"},
+		map[string]interface{}{"integerValue": true},
+		map[string]interface{}{"doubleValue": []interface{}{}},
+		map[string]interface{}{"timestampValue": "yesterday"},
+		map[string]interface{}{"stringValue": 1},
+		map[string]interface{}{"geoPointValue": "here"},
+		map[string]interface{}{"arrayValue": []interface{}{}},
+		map[string]interface{}{"arrayValue": map[string]interface{}{"values": []interface{}{"bare"}}},
+		map[string]interface{}{"mapValue": "fields"},
+	} {
+		if got, err := ParseFirestoreValue(value); err == nil {
+			t.Errorf("ParseFirestoreValue(%#v) = %#v, want an error", value, got)
+		}
+	}
+}
+
+func TestDecodeFields(t *testing.T) {
+	decoded, err := DecodeFields(map[string]interface{}{
+		"name":   map[string]interface{}{"stringValue": "Pizza Place"},
+		"orders": map[string]interface{}{"integerValue": "12"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"name": "Pizza Place", "orders": int64(12)}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("DecodeFields = %v, want %v", decoded, want)
+	}
+
+	_, err = DecodeFields(map[string]interface{}{"orders": map[string]interface{}{"integerValue": "twelve"}})
+	if err == nil {
+		t.Fatal("DecodeFields accepted an invalid integerValue")
+	}
+}
+
+// FuzzParseFirestoreValue checks that arbitrary JSON never panics the decoder,
+// which reads values straight from Firestore responses.
+func FuzzParseFirestoreValue(f *testing.F) {
+	for _, seed := range []string{
+		`{"nullValue": null}`,
+		`{"integerValue": "9007199254740993"}`,
+		`{"integerValue": 12}`,
+		`{"doubleValue": "Infinity"}`,
+		`{"timestampValue": "2024-12-16T09:30:00.123456Z"}`,
+		`{"geoPointValue": {"latitude": 1}}`,
+		`{"arrayValue": {"values": [{"stringValue": "a"}, {"mapValue": {}}]}}`,
+		`{"mapValue": {"fields": {"a": {"arrayValue": {"values": [{"nullValue": null}]}}}}}`,
+		`{"mapValue": {"fields": {"a": "bare"}}}`,
+		`[]`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, raw string) {
+		dec := json.NewDecoder(bytes.NewReader([]byte(raw)))
+		dec.UseNumber()
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return
+		}
+		if decoded, err := ParseFirestoreValue(value); err == nil {
+			if _, err := json.Marshal(decoded); err != nil && !isUnsupportedFloat(err) {
+				t.Errorf("decoded value %#v does not encode: %v", decoded, err)
+			}
+		}
+	})
+}
+
+// isUnsupportedFloat reports whether err is json's refusal to encode NaN or
+// ±Inf, which Firestore's doubleValue can hold.
+func isUnsupportedFloat(err error) bool {
+	_, ok := err.(*json.UnsupportedValueError)
+	return ok
+}
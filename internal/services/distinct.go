@@ -0,0 +1,58 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DistinctValues returns the sorted, de-duplicated decoded values found at a
+// dot-notation field path across documents. Array values contribute each of
+// their elements; missing and null values are skipped.
+func DistinctValues(docs []FirestoreDocument, field string) []interface{} {
+	seen := make(map[string]bool)
+	values := []interface{}{}
+
+	add := func(v interface{}) {
+		if v == nil {
+			return
+		}
+		key := distinctKey(v)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		values = append(values, v)
+	}
+
+	for _, doc := range docs {
+		decoded, err := DecodeFields(doc.Fields)
+		if err != nil {
+			continue
+		}
+		value, ok := LookupPath(decoded, field)
+		if !ok {
+			continue
+		}
+		if items, ok := value.([]interface{}); ok {
+			for _, item := range items {
+				add(item)
+			}
+			continue
+		}
+		add(value)
+	}
+
+	sort.SliceStable(values, func(a, b int) bool {
+		return compareValues(values[a], values[b]) < 0
+	})
+	return values
+}
+
+// distinctKey identifies a decoded value by kind and content.
+func distinctKey(v interface{}) string {
+	if t, ok := v.(time.Time); ok {
+		return "time:" + t.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("%d:%v", valueRank(v), v)
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DocumentPath validates a document path relative to the documents root (e.g.
+// "restaurants/R001") and returns it with each segment URL-escaped.
+func DocumentPath(documentPath string) (string, error) {
+	segments := strings.Split(strings.Trim(documentPath, "/"), "/")
+	if len(segments)%2 != 0 {
+		return "", fmt.Errorf("invalid document path %q, expected collection/document[/collection/document...]", documentPath)
+	}
+	for i, segment := range segments {
+		if segment == "" {
+			return "", fmt.Errorf("invalid document path %q: empty segment", documentPath)
+		}
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// FetchDocument reads a single document by its path relative to the documents
+// root. When fields is non-empty only those field paths are returned, using a
+// document mask.
+func FetchDocument(ctx context.Context, projectID, databaseID, documentPath string, fields []string) (FirestoreDocument, error) {
+	path, err := DocumentPath(documentPath)
+	if err != nil {
+		return FirestoreDocument{}, err
+	}
+
+	query := url.Values{}
+	for _, field := range fields {
+		fieldPath, err := EscapeFieldPath(field)
+		if err != nil {
+			return FirestoreDocument{}, err
+		}
+		query.Add("mask.fieldPaths", fieldPath)
+	}
+	addReadQuery(ctx, query)
+
+	requestURL := documentsURL(ctx, projectID, databaseID) + "/" + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+
+	var document FirestoreDocument
+	if err := firestoreRequest(ctx, "GET", requestURL, nil, &document); err != nil {
+		return FirestoreDocument{}, err
+	}
+	document.ID = DocumentID(document.Name)
+	return document, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"crossfire-grafana/internal/metrics"
+)
+
+// ErrDatabaseNotFound matches errors caused by a Firestore database that does not exist.
+var ErrDatabaseNotFound = errors.New("firestore database not found")
+
+// ErrDocumentNotFound matches errors caused by reading a document that does not exist.
+var ErrDocumentNotFound = errors.New("firestore document not found")
+
+// ErrQuotaExceeded matches errors caused by an exhausted Firestore quota.
+var ErrQuotaExceeded = errors.New("firestore quota exceeded")
+
+// quotaExceeded counts Firestore calls rejected for exhausted quota.
+var quotaExceeded = metrics.NewCounter("firestore_quota_exceeded_total", "Firestore calls rejected with RESOURCE_EXHAUSTED.", "database")
+
+// databasePattern extracts the database ID from a Firestore request URL.
+var databasePattern = regexp.MustCompile(`/databases/([^/:]+)`)
+
+// APIError is an error response from the Firestore REST API. Status, Message
+// and Details are decoded from the body's error object, when it has one.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Message    string
+	Details    []interface{}
+	Database   string
+	RetryAfter string
+}
+
+// Error describes the Firestore error.
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("Firestore API returned error: %d %s", e.StatusCode, e.Status)
+	}
+	return fmt.Sprintf("Firestore API returned error: %s: %s", e.Status, e.Message)
+}
+
+// Is reports whether the error matches a sentinel such as ErrDatabaseNotFound.
+func (e *APIError) Is(target error) bool {
+	if target == ErrDatabaseNotFound {
+		return e.StatusCode == 404 && e.missingDatabase()
+	}
+	if target == ErrDocumentNotFound {
+		return e.StatusCode == 404 && !e.missingDatabase()
+	}
+	if target == ErrQuotaExceeded {
+		return e.StatusCode == 429 && e.Status == "RESOURCE_EXHAUSTED"
+	}
+	return false
+}
+
+// missingDatabase reports whether a 404 message is about the database rather
+// than a document (whose message also names the database in its path).
+func (e *APIError) missingDatabase() bool {
+	message := strings.ToLower(e.Message)
+	return strings.Contains(message, "database") && !strings.HasPrefix(message, "document")
+}
+
+// newAPIError builds an APIError from a non-200 response body.
+func newAPIError(statusCode int, requestURL string, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode}
+	if match := databasePattern.FindStringSubmatch(requestURL); match != nil {
+		apiErr.Database = match[1]
+	}
+
+	var parsed struct {
+		Error struct {
+			Status  string        `json:"status"`
+			Message string        `json:"message"`
+			Details []interface{} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		apiErr.Status = parsed.Error.Status
+		apiErr.Message = parsed.Error.Message
+		apiErr.Details = parsed.Error.Details
+	}
+	return apiErr
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// queryExplainKey is the context key holding a *QueryExplain.
+type queryExplainKey struct{}
+
+// QueryExplain collects the explainMetrics Firestore returns for the runQuery
+// calls made while serving a request: the plan's index usage and, since the
+// queries are analyzed, the documents scanned and execution stats.
+type QueryExplain struct {
+	mu      sync.Mutex
+	metrics []json.RawMessage
+}
+
+// WithQueryExplain returns a context whose runQuery calls ask Firestore to
+// analyze the query and record its metrics into a new QueryExplain.
+func WithQueryExplain(ctx context.Context) (context.Context, *QueryExplain) {
+	explain := &QueryExplain{}
+	return context.WithValue(ctx, queryExplainKey{}, explain), explain
+}
+
+// queryExplainFrom returns the QueryExplain attached to ctx, if any.
+func queryExplainFrom(ctx context.Context) *QueryExplain {
+	explain, _ := ctx.Value(queryExplainKey{}).(*QueryExplain)
+	return explain
+}
+
+// Metrics returns the recorded explainMetrics, one per query, in the order
+// the queries completed.
+func (e *QueryExplain) Metrics() []json.RawMessage {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]json.RawMessage{}, e.metrics...)
+}
+
+// addExplainPayload asks for analyzed explain metrics in a runQuery payload
+// when ctx is explaining.
+func addExplainPayload(ctx context.Context, payload map[string]interface{}) {
+	if queryExplainFrom(ctx) != nil {
+		payload["explainOptions"] = map[string]interface{}{"analyze": true}
+	}
+}
+
+// recordExplainMetrics records a query's explainMetrics on the context's
+// request, if it is explaining.
+func recordExplainMetrics(ctx context.Context, metrics json.RawMessage) {
+	explain := queryExplainFrom(ctx)
+	if explain == nil || len(metrics) == 0 {
+		return
+	}
+	explain.mu.Lock()
+	defer explain.mu.Unlock()
+	explain.metrics = append(explain.metrics, metrics)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// simpleSegment matches field names Firestore accepts without quoting.
+var simpleSegment = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z_0-9]*$`)
+
+// FieldPath joins field names into a Firestore field path, backtick-quoting
+// names that contain dots, spaces, backticks or other special characters.
+func FieldPath(segments ...string) string {
+	quoted := make([]string, len(segments))
+	for i, segment := range segments {
+		quoted[i] = quoteSegment(segment)
+	}
+	return strings.Join(quoted, ".")
+}
+
+// quoteSegment backtick-quotes a single field name when needed, escaping
+// backslashes and backticks inside it.
+func quoteSegment(segment string) string {
+	if simpleSegment.MatchString(segment) {
+		return segment
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(segment)
+	return "`" + escaped + "`"
+}
+
+// EscapeFieldPath normalizes a dot-notation path for use as a structuredQuery
+// fieldPath. Segments may already be backtick-quoted (for names containing
+// dots, e.g. "`a.b`.c"); unquoted segments with special characters such as
+// spaces are quoted.
+func EscapeFieldPath(path string) (string, error) {
+	segments, err := splitFieldPath(path)
+	if err != nil {
+		return "", err
+	}
+	return FieldPath(segments...), nil
+}
+
+// splitFieldPath splits a dot-notation path into field names, honoring
+// backtick-quoted segments and backslash escapes inside them.
+func splitFieldPath(path string) ([]string, error) {
+	if path == "" {
+		return nil, fmt.Errorf("field path is empty")
+	}
+
+	var segments []string
+	var current strings.Builder
+	quoted, escaped, wasQuoted := false, false, false
+	for _, r := range path {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '`':
+			quoted = !quoted
+			wasQuoted = true
+		case r == '.' && !quoted:
+			if current.Len() == 0 && !wasQuoted {
+				return nil, fmt.Errorf("invalid field path %q: empty segment", path)
+			}
+			segments = append(segments, current.String())
+			current.Reset()
+			wasQuoted = false
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if quoted || escaped {
+		return nil, fmt.Errorf("invalid field path %q: unterminated backtick", path)
+	}
+	if current.Len() == 0 && !wasQuoted {
+		return nil, fmt.Errorf("invalid field path %q: empty segment", path)
+	}
+	return append(segments, current.String()), nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FilterContains returns the documents whose decoded value at a dot-notation
+// field path contains substring, ignoring case. Array fields match when any
+// element does; missing and null values never match.
+func FilterContains(docs []FirestoreDocument, field, substring string) []FirestoreDocument {
+	needle := strings.ToLower(substring)
+	matched := []FirestoreDocument{}
+	for _, doc := range docs {
+		decoded, err := DecodeFields(doc.Fields)
+		if err != nil {
+			continue
+		}
+		value, _ := LookupPath(decoded, field)
+		if containsValue(value, needle) {
+			matched = append(matched, doc)
+		}
+	}
+	return matched
+}
+
+// FilterModifiedSince returns the documents whose updateTime is after since.
+// Firestore queries cannot filter on the update time, so this runs on fetched
+// documents; documents without an updateTime are excluded.
+func FilterModifiedSince(docs []FirestoreDocument, since time.Time) []FirestoreDocument {
+	matched := []FirestoreDocument{}
+	for _, doc := range docs {
+		if !doc.UpdateTime.IsZero() && doc.UpdateTime.After(since) {
+			matched = append(matched, doc)
+		}
+	}
+	return matched
+}
+
+// containsValue reports whether a decoded value's text contains the lower-cased needle.
+func containsValue(value interface{}, needle string) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case []interface{}:
+		for _, item := range v {
+			if containsValue(item, needle) {
+				return true
+			}
+		}
+		return false
+	case string:
+		return strings.Contains(strings.ToLower(v), needle)
+	case time.Time:
+		return strings.Contains(strings.ToLower(v.Format(time.RFC3339Nano)), needle)
+	}
+	return strings.Contains(strings.ToLower(fmt.Sprint(value)), needle)
+}
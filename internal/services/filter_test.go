@@ -0,0 +1,37 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilterModifiedSince(t *testing.T) {
+	since := time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+	docs := []FirestoreDocument{
+		{ID: "before", UpdateTime: since.Add(-time.Second)},
+		{ID: "at", UpdateTime: since},
+		{ID: "after", UpdateTime: since.Add(time.Nanosecond)},
+		{ID: "later", UpdateTime: since.Add(24 * time.Hour)},
+		{ID: "no update time"},
+	}
+
+	tests := []struct {
+		since time.Time
+		want  []string
+	}{
+		{since, []string{"after", "later"}},
+		{since.Add(-time.Hour), []string{"before", "at", "after", "later"}},
+		{since.Add(48 * time.Hour), []string{}},
+		{since.In(time.FixedZone("AEDT", 11*60*60)), []string{"after", "later"}},
+	}
+	for _, tt := range tests {
+		ids := []string{}
+		for _, doc := range FilterModifiedSince(docs, tt.since) {
+			ids = append(ids, doc.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("FilterModifiedSince(%s) = %q, want %q", tt.since.Format(time.RFC3339Nano), ids, tt.want)
+		}
+	}
+}
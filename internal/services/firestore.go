@@ -2,197 +2,208 @@ package services
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"log"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/oauth2/google"
 )
 
+// defaultMaxPages bounds the pagination loop when MAX_PAGES is not set.
+const defaultMaxPages = 100
+
 // FirestoreDocument represents a Firestore document.
 type FirestoreDocument struct {
-	Name   string                 `json:"name"`
-	Fields map[string]interface{} `json:"fields"`
+	ID         string                 `json:"id"`
+	Name       string                 `json:"name"`
+	Fields     map[string]interface{} `json:"fields"`
+	CreateTime time.Time              `json:"createTime"`
+	UpdateTime time.Time              `json:"updateTime"`
+}
+
+// DocumentID returns the short document ID (the last path segment) of a
+// full Firestore resource name.
+func DocumentID(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// setDocumentIDs fills in the short ID of each document from its name.
+func setDocumentIDs(docs []FirestoreDocument) {
+	for i := range docs {
+		docs[i].ID = DocumentID(docs[i].Name)
+	}
 }
 
-// GetFirestoreAccessToken generates an OAuth token for Firestore.
-func GetFirestoreAccessToken() (string, error) {
-	ctx := context.Background()
-	creds, err := google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/datastore")
+// datastoreScope is the OAuth scope required for Firestore access.
+const datastoreScope = "https://www.googleapis.com/auth/datastore"
+
+// GetFirestoreAccessToken returns an OAuth token for Firestore, reusing the
+// last token until it expires and retrying transient token failures until
+// ctx's deadline. The token belongs to the credential profile selected on ctx,
+// or to the default credentials. The emulator accepts the fixed "owner" token.
+func GetFirestoreAccessToken(ctx context.Context) (string, error) {
+	if emulatorHost != "" {
+		return "owner", nil
+	}
+
+	if name := profileFrom(ctx); name != "" {
+		return profileAccessToken(ctx, name)
+	}
+
+	if token, ok := defaultTokens.get(); ok {
+		return token, nil
+	}
+
+	// A missing or invalid credential is permanent; fail fast without retrying
+	creds, err := google.FindDefaultCredentials(context.Background(), datastoreScope)
 	if err != nil {
 		return "", fmt.Errorf("failed to find default credentials: %v", err)
 	}
 
-	token, err := creds.TokenSource.Token()
-	if err != nil {
-		return "", fmt.Errorf("failed to generate access token: %v", err)
+	return fetchToken(ctx, creds.TokenSource, defaultTokens)
+}
+
+// CheckCredentials verifies that Firestore credentials can be found, returning
+// an actionable error listing the ways to provide them when they can't.
+func CheckCredentials(ctx context.Context) error {
+	if emulatorHost != "" {
+		return nil
+	}
+	if _, err := google.FindDefaultCredentials(ctx, datastoreScope); err != nil {
+		return fmt.Errorf("no Google Cloud credentials found (%v). Provide them in one of these ways:\n"+
+			"  1. set GOOGLE_APPLICATION_CREDENTIALS to a service account key file\n"+
+			"  2. run `gcloud auth application-default login`\n"+
+			"  3. set FIRESTORE_EMULATOR_HOST to use a local Firestore emulator", err)
 	}
-	return token.AccessToken, nil
+	return nil
 }
 
+// maxPages returns the configured MAX_PAGES limit, falling back to the default.
+func maxPages(ctx context.Context) int {
+	value, err := strconv.Atoi(setting(ctx, "MAX_PAGES"))
+	if err != nil || value <= 0 {
+		return defaultMaxPages
+	}
+	return value
+}
 
-func FetchDocumentsFromFirestore(projectID, databaseID, collection string) ([]FirestoreDocument, error) {
-	url := fmt.Sprintf("https://firestore.googleapis.com/v1/projects/%s/databases/%s/documents/%s", projectID, databaseID, collection)
+// FetchDocumentsFromFirestore lists every document in a collection, following
+// pagination up to MAX_PAGES pages. The returned bool reports whether the
+// listing was truncated because the page limit or call budget was reached.
+// With DEDUPE=true documents returned on more than one page are kept once.
+func FetchDocumentsFromFirestore(ctx context.Context, projectID, databaseID, collection string) ([]FirestoreDocument, bool, error) {
+	baseURL := fmt.Sprintf("%s/%s", documentsURL(ctx, projectID, databaseID), collection)
 
 	var allDocuments []FirestoreDocument
 	var nextPageToken string
+	limit := maxPages(ctx)
+
+	// A document moved mid-scan can appear on two pages; with DEDUPE=true only
+	// its first occurrence is kept
+	dedupe := setting(ctx, "DEDUPE") == "true"
+	seen := make(map[string]bool)
+	dropped := 0
+	defer func() {
+		if dropped > 0 {
+			log.Printf("Dropped %d duplicate documents while paginating %s", dropped, collection)
+		}
+	}()
 
-	for {
+	for page := 1; ; page++ {
 		// Construct the URL with pagination if a next page token exists
-		requestURL := url
+		query := url.Values{}
 		if nextPageToken != "" {
-			requestURL = fmt.Sprintf("%s?pageToken=%s", url, nextPageToken)
-		}
-
-		// Get Firestore access token
-		token, err := GetFirestoreAccessToken()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get access token: %v", err)
+			query.Set("pageToken", nextPageToken)
 		}
-
-		// Create the request
-		req, err := http.NewRequest("GET", requestURL, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %v", err)
+		addReadQuery(ctx, query)
+		requestURL := baseURL
+		if len(query) > 0 {
+			requestURL += "?" + query.Encode()
 		}
-		req.Header.Set("Authorization", "Bearer "+token)
 
-		// Make the request
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to make request: %v", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("firestore API returned error: %s", resp.Status)
-		}
-
-		// Decode the response
 		var result struct {
-			Documents      []FirestoreDocument `json:"documents"`
-			NextPageToken  string              `json:"nextPageToken"`
+			Documents     []FirestoreDocument `json:"documents"`
+			NextPageToken string              `json:"nextPageToken"`
 		}
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return nil, fmt.Errorf("failed to parse response: %v", err)
+		if err := firestoreRequest(ctx, "GET", requestURL, nil, &result); err != nil {
+			// Keep the pages fetched so far once the call budget runs out
+			if errors.Is(err, ErrBudgetExceeded) && page > 1 {
+				setDocumentIDs(allDocuments)
+				return allDocuments, true, nil
+			}
+			return nil, false, err
 		}
 
 		// Append the documents from this page
-		allDocuments = append(allDocuments, result.Documents...)
+		for _, doc := range result.Documents {
+			if dedupe {
+				if seen[doc.Name] {
+					dropped++
+					continue
+				}
+				seen[doc.Name] = true
+			}
+			allDocuments = append(allDocuments, doc)
+		}
 
 		// Check if there is another page of documents
 		if result.NextPageToken == "" {
 			break
 		}
 		nextPageToken = result.NextPageToken
+
+		// Stop once the page limit is reached, even if Firestore has more
+		if page >= limit {
+			log.Printf("Warning: stopped paginating %s after %d pages (MAX_PAGES reached)", collection, limit)
+			setDocumentIDs(allDocuments)
+			return allDocuments, true, nil
+		}
 	}
 
-	return allDocuments, nil
+	setDocumentIDs(allDocuments)
+	return allDocuments, false, nil
 }
 
+// descendantsQuery builds a runQuery payload selecting every collection with the
+// given ID, at any depth.
+func descendantsQuery(ctx context.Context, collectionID string) map[string]interface{} {
+	payload := map[string]interface{}{
+		"structuredQuery": map[string]interface{}{
+			"from": []map[string]interface{}{
+				{"collectionId": collectionID, "allDescendants": true},
+			},
+		},
+	}
+	addReadPayload(ctx, payload)
+	return payload
+}
 
 // FetchDocumentsFromFirestoreWithSubcollection queries a Firestore subcollection.
-func FetchDocumentsFromFirestoreWithSubcollection(projectID, databaseID, subCollection string) ([]FirestoreDocument, error) {
-	url := fmt.Sprintf(
-		"https://firestore.googleapis.com/v1/projects/%s/databases/%s/documents:runQuery",
-		projectID, databaseID,
-	)
-
-	payload := fmt.Sprintf(`{
-        "structuredQuery": {
-            "from": [{"collectionId": "%s", "allDescendants": true}]
-        }
-    }`, subCollection)
-
-	req, err := http.NewRequest("POST", url, strings.NewReader(payload))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-
-	token, err := GetFirestoreAccessToken()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get access token: %v", err)
-	}
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Firestore API returned error: %s", resp.Status)
-	}
-
-	var result []struct {
-		Document FirestoreDocument `json:"document"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
-	}
-
-	var documents []FirestoreDocument
-	for _, res := range result {
-		documents = append(documents, res.Document)
-	}
-
-	return documents, nil
+func FetchDocumentsFromFirestoreWithSubcollection(ctx context.Context, projectID, databaseID, subCollection string) ([]FirestoreDocument, error) {
+	queryURL := documentsURL(ctx, projectID, databaseID) + ":runQuery"
+	return streamRunQuery(ctx, queryURL, descendantsQuery(ctx, subCollection))
 }
 
 // FetchSpecificDocumentsFromFirestore queries a specific Firestore collection.
-func FetchSpecificDocumentsFromFirestore(projectID, databaseID, parentCollection, subCollection string) ([]map[string]interface{}, error) {
-	url := fmt.Sprintf(
-		"https://firestore.googleapis.com/v1/projects/%s/databases/%s/documents:runQuery",
-		projectID, databaseID,
-	)
-
-	payload := fmt.Sprintf(`{
-		"structuredQuery": {
-			"from": [{"collectionId": "%s", "allDescendants": true}]
-		}
-	}`, subCollection)
+func FetchSpecificDocumentsFromFirestore(ctx context.Context, projectID, databaseID, parentCollection, subCollection string) ([]map[string]interface{}, error) {
+	queryURL := documentsURL(ctx, projectID, databaseID) + ":runQuery"
 
-	req, err := http.NewRequest("POST", url, strings.NewReader(payload))
+	result, err := streamRunQuery(ctx, queryURL, descendantsQuery(ctx, subCollection))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-
-	token, err := GetFirestoreAccessToken()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get access token: %v", err)
-	}
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Firestore API returned error: %s", resp.Status)
-	}
-
-	var result []struct {
-		Document struct {
-			Name   string                 `json:"name"`
-			Fields map[string]interface{} `json:"fields"`
-		} `json:"document"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
+		return nil, err
 	}
 
 	var documents []map[string]interface{}
-	for _, res := range result {
-		if res.Document.Fields != nil {
+	for _, doc := range result {
+		if doc.Fields != nil {
 			documents = append(documents, map[string]interface{}{
-				"name":        res.Document.Name,
-				"fields":      res.Document.Fields,
+				"id":          doc.ID,
+				"name":        doc.Name,
+				"fields":      doc.Fields,
 				"subCategory": subCollection,
 			})
 		}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"crossfire-grafana/internal/config"
+)
+
+// fakeFirestore points Firestore calls at a test server running handler for
+// the duration of a test, the way FIRESTORE_EMULATOR_HOST does.
+func fakeFirestore(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	saved := emulatorHost
+	emulatorHost = strings.TrimPrefix(server.URL, "http://")
+	t.Cleanup(func() {
+		emulatorHost = saved
+		server.Close()
+	})
+}
+
+// withSettings returns a context served with a Runtime holding env.
+func withSettings(env map[string]string) context.Context {
+	rt := &config.Runtime{Fields: &config.FieldConfig{Collections: map[string]config.CollectionConfig{}}, Env: env}
+	return config.WithRuntime(context.Background(), rt)
+}
+
+func TestFetchDocumentsDedupe(t *testing.T) {
+	pages := map[string]string{
+		"": `{"documents": [
+			{"name": "projects/p/databases/d/documents/orders/a"},
+			{"name": "projects/p/databases/d/documents/orders/b"}
+		], "nextPageToken": "2"}`,
+		"2": `{"documents": [
+			{"name": "projects/p/databases/d/documents/orders/b"},
+			{"name": "projects/p/databases/d/documents/orders/c"}
+		]}`,
+	}
+	fakeFirestore(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(pages[r.URL.Query().Get("pageToken")]))
+	})
+
+	tests := []struct {
+		dedupe string
+		want   []string
+	}{
+		{"", []string{"a", "b", "b", "c"}},
+		{"true", []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		documents, truncated, err := FetchDocumentsFromFirestore(withSettings(map[string]string{"DEDUPE": tt.dedupe}), "p", "d", "orders")
+		if err != nil || truncated {
+			t.Fatalf("DEDUPE=%q: truncated %v, error %v", tt.dedupe, truncated, err)
+		}
+		var ids []string
+		for _, doc := range documents {
+			ids = append(ids, doc.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("DEDUPE=%q: IDs = %q, want %q", tt.dedupe, ids, tt.want)
+		}
+	}
+}
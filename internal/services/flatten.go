@@ -0,0 +1,84 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// MaxFlattenDepth is the nesting depth below which values are kept as
+	// nested JSON under their dotted key instead of being flattened further.
+	MaxFlattenDepth = 32
+	// MaxFlattenKeys is the most keys a single flattened document may have.
+	MaxFlattenKeys = 10000
+)
+
+// FlattenOptions adjusts how Flatten names keys.
+type FlattenOptions struct {
+	// CollapseSingletonArrays emits the only element of a one-element array
+	// under the array's key (tags instead of tags.0).
+	CollapseSingletonArrays bool
+}
+
+// Flatten converts a decoded document into a single-level map with dotted keys
+// for nested maps (billTo.state) and indexed keys for arrays (items.0.sku).
+// Empty maps and arrays are kept as values. It fails if the document would
+// produce more than MaxFlattenKeys keys.
+func Flatten(decoded map[string]interface{}, opts FlattenOptions) (map[string]interface{}, error) {
+	flat := make(map[string]interface{})
+	if err := flattenInto(flat, "", decoded, 0, opts); err != nil {
+		return nil, err
+	}
+	return flat, nil
+}
+
+// flattenInto writes value into flat under prefix, descending into maps and arrays.
+func flattenInto(flat map[string]interface{}, prefix string, value interface{}, depth int, opts FlattenOptions) error {
+	if depth > MaxFlattenDepth {
+		return setFlat(flat, prefix, value)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && prefix != "" {
+			return setFlat(flat, prefix, v)
+		}
+		for key, item := range v {
+			if err := flattenInto(flat, joinKey(prefix, key), item, depth+1, opts); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return setFlat(flat, prefix, v)
+		}
+		if len(v) == 1 && opts.CollapseSingletonArrays && prefix != "" {
+			return flattenInto(flat, prefix, v[0], depth+1, opts)
+		}
+		for i, item := range v {
+			if err := flattenInto(flat, joinKey(prefix, strconv.Itoa(i)), item, depth+1, opts); err != nil {
+				return err
+			}
+		}
+	default:
+		return setFlat(flat, prefix, v)
+	}
+	return nil
+}
+
+// setFlat stores a flattened value, enforcing MaxFlattenKeys.
+func setFlat(flat map[string]interface{}, key string, value interface{}) error {
+	if len(flat) >= MaxFlattenKeys {
+		return fmt.Errorf("document has more than %d fields when flattened", MaxFlattenKeys)
+	}
+	flat[key] = value
+	return nil
+}
+
+// joinKey appends a key segment to a dotted prefix.
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
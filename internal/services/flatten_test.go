@@ -0,0 +1,82 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		name    string
+		decoded map[string]interface{}
+		opts    FlattenOptions
+		want    map[string]interface{}
+	}{
+		{
+			name:    "nested maps",
+			decoded: map[string]interface{}{"billTo": map[string]interface{}{"state": "NSW", "address": map[string]interface{}{"suburb": "Bondi"}}},
+			want:    map[string]interface{}{"billTo.state": "NSW", "billTo.address.suburb": "Bondi"},
+		},
+		{
+			name:    "arrays",
+			decoded: map[string]interface{}{"items": []interface{}{map[string]interface{}{"sku": "A1"}, "loose"}},
+			want:    map[string]interface{}{"items.0.sku": "A1", "items.1": "loose"},
+		},
+		{
+			name:    "empty containers",
+			decoded: map[string]interface{}{"tags": []interface{}{}, "meta": map[string]interface{}{}},
+			want:    map[string]interface{}{"tags": []interface{}{}, "meta": map[string]interface{}{}},
+		},
+		{
+			name:    "singleton arrays kept",
+			decoded: map[string]interface{}{"tags": []interface{}{"vip"}},
+			want:    map[string]interface{}{"tags.0": "vip"},
+		},
+		{
+			name:    "singleton arrays collapsed",
+			decoded: map[string]interface{}{"tags": []interface{}{"vip"}, "items": []interface{}{map[string]interface{}{"sku": "A1"}}},
+			opts:    FlattenOptions{CollapseSingletonArrays: true},
+			want:    map[string]interface{}{"tags": "vip", "items.sku": "A1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Flatten(tt.decoded, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Flatten = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlattenStopsAtMaxDepth(t *testing.T) {
+	var value interface{} = "leaf"
+	for i := 0; i < MaxFlattenDepth+5; i++ {
+		value = map[string]interface{}{"a": value}
+	}
+	flat, err := Flatten(value.(map[string]interface{}), FlattenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flat) != 1 {
+		t.Fatalf("Flatten produced %d keys, want 1", len(flat))
+	}
+	for key, v := range flat {
+		if _, ok := v.(map[string]interface{}); !ok {
+			t.Errorf("value below the max depth under %q is %T, want the nested map", key, v)
+		}
+	}
+}
+
+func TestFlattenRejectsTooManyKeys(t *testing.T) {
+	items := make([]interface{}, MaxFlattenKeys+1)
+	for i := range items {
+		items[i] = i
+	}
+	if _, err := Flatten(map[string]interface{}{"items": items}, FlattenOptions{}); err == nil {
+		t.Fatal("Flatten accepted a document with more than MaxFlattenKeys keys")
+	}
+}
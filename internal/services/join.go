@@ -0,0 +1,58 @@
+package services
+
+// JoinDocuments left-joins two document sets on a field. on is "id" for the
+// document ID, or a dot-notation field path present in both sets. Each left
+// document is returned with the matching right document's fields nested as a
+// map under rightName; left documents without a match get an empty map, and
+// right documents without a match are dropped. When several right documents
+// share a key, the first one wins.
+func JoinDocuments(left, right []FirestoreDocument, on, rightName string) []FirestoreDocument {
+	byKey := make(map[string]FirestoreDocument, len(right))
+	for _, doc := range right {
+		key, ok := documentKey(doc, on)
+		if !ok {
+			continue
+		}
+		if _, exists := byKey[key]; !exists {
+			byKey[key] = doc
+		}
+	}
+
+	joined := make([]FirestoreDocument, 0, len(left))
+	for _, doc := range left {
+		rightFields := map[string]interface{}{}
+		if key, ok := documentKey(doc, on); ok {
+			if match, found := byKey[key]; found && match.Fields != nil {
+				rightFields = match.Fields
+			}
+		}
+
+		fields := make(map[string]interface{}, len(doc.Fields)+1)
+		for name, value := range doc.Fields {
+			fields[name] = value
+		}
+		fields[rightName] = map[string]interface{}{
+			"mapValue": map[string]interface{}{"fields": rightFields},
+		}
+		doc.Fields = fields
+		joined = append(joined, doc)
+	}
+	return joined
+}
+
+// documentKey returns the join key of a document: its ID for "id", otherwise
+// the decoded value at the field path. Documents missing the field have no key.
+func documentKey(doc FirestoreDocument, on string) (string, bool) {
+	if on == "id" {
+		return doc.ID, true
+	}
+	decoded, err := DecodeFields(doc.Fields)
+	if err != nil {
+		return "", false
+	}
+	value, _ := LookupPath(decoded, on)
+	if value == nil {
+		return "", false
+	}
+	return distinctKey(value), true
+}
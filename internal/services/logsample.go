@@ -0,0 +1,58 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync/atomic"
+
+	"crossfire-grafana/internal/config"
+)
+
+// LogSampler rate-limits a per-document log line to 1 in every N occurrences
+// (LOG_DOCUMENT_SAMPLE_RATE, default 1) while counting all of them, so large
+// collections don't flood the log.
+type LogSampler struct {
+	every uint64
+	seen  atomic.Uint64
+}
+
+// NewLogSampler creates a sampler using the LOG_DOCUMENT_SAMPLE_RATE of rt.
+func NewLogSampler(rt *config.Runtime) *LogSampler {
+	s := &LogSampler{every: 1}
+	if value, err := strconv.ParseUint(rt.Getenv("LOG_DOCUMENT_SAMPLE_RATE"), 10, 64); err == nil && value > 0 {
+		s.every = value
+	}
+	return s
+}
+
+// Printf counts an occurrence and logs it if it is sampled: the first one and
+// every Nth after it. A nil sampler logs everything.
+func (s *LogSampler) Printf(format string, args ...interface{}) {
+	if s == nil {
+		log.Printf(format, args...)
+		return
+	}
+	if (s.seen.Add(1)-1)%s.every == 0 {
+		log.Printf(format, args...)
+	}
+}
+
+// Total returns how many occurrences were counted.
+func (s *LogSampler) Total() uint64 {
+	return s.seen.Load()
+}
+
+// Summary logs the total count, noting the sampling rate, when there was at
+// least one occurrence.
+func (s *LogSampler) Summary(what string) {
+	total := s.Total()
+	if total == 0 {
+		return
+	}
+	sampled := ""
+	if s.every > 1 {
+		sampled = fmt.Sprintf(" (logged 1 in %d)", s.every)
+	}
+	log.Printf("%s: %d total%s", what, total, sampled)
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// memoKey is the context key holding a *Memo.
+type memoKey struct{}
+
+// Memo remembers the results of a request's backend reads, so a collection
+// read twice during one request is fetched from Firestore once. Concurrent
+// identical reads wait for the first one instead of issuing their own.
+type Memo struct {
+	mu    sync.Mutex
+	calls map[string]*memoCall
+}
+
+// memoCall is a read that is in flight or finished.
+type memoCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// NewMemo returns an empty request memo.
+func NewMemo() *Memo {
+	return &Memo{calls: make(map[string]*memoCall)}
+}
+
+// WithMemo returns a context whose backend reads are remembered in memo.
+func WithMemo(ctx context.Context, memo *Memo) context.Context {
+	return context.WithValue(ctx, memoKey{}, memo)
+}
+
+// Clear forgets every remembered result.
+func (m *Memo) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = make(map[string]*memoCall)
+}
+
+// memoize returns the remembered result of the read named key, or runs fetch
+// and remembers it. A failed read is shared with the reads waiting on it but
+// not remembered for later ones. Without a memo on the context fetch always
+// runs.
+func memoize(ctx context.Context, key string, fetch func() (interface{}, error)) (interface{}, error) {
+	m, _ := ctx.Value(memoKey{}).(*Memo)
+	if m == nil {
+		return fetch()
+	}
+
+	m.mu.Lock()
+	if call, ok := m.calls[key]; ok {
+		m.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &memoCall{done: make(chan struct{})}
+	m.calls[key] = call
+	m.mu.Unlock()
+
+	call.value, call.err = fetch()
+	if call.err != nil {
+		m.mu.Lock()
+		if m.calls[key] == call {
+			delete(m.calls, key)
+		}
+		m.mu.Unlock()
+	}
+	close(call.done)
+	return call.value, call.err
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// profileKey is the context key holding the selected credential profile.
+type profileKey struct{}
+
+// WithProfile returns a context whose Firestore calls authenticate with the
+// named credential profile.
+func WithProfile(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, profileKey{}, name)
+}
+
+// profileFrom returns the credential profile selected on ctx, if any.
+func profileFrom(ctx context.Context) string {
+	name, _ := ctx.Value(profileKey{}).(string)
+	return name
+}
+
+// profileVariable returns the variable holding a profile's credentials:
+// CREDS_ followed by the upper-cased name, with other characters than
+// letters and digits replaced by underscores.
+func profileVariable(name string) string {
+	return "CREDS_" + profileSuffix(name)
+}
+
+// profileSuffix returns a profile's name as used in its variables.
+func profileSuffix(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// ProfileExists reports whether credentials are configured for a profile.
+func ProfileExists(ctx context.Context, name string) bool {
+	return name != "" && setting(ctx, profileVariable(name)) != ""
+}
+
+// credentialProfile is a loaded profile with its own token cache.
+type credentialProfile struct {
+	source oauth2.TokenSource
+	tokens *tokenCache
+	// projectID is the project of the profile's service account key, if any
+	projectID string
+}
+
+var (
+	profilesMu sync.Mutex
+	// profiles are keyed by name and credentials, so a reload that changes a
+	// profile's credentials loads them afresh
+	profiles = make(map[string]*credentialProfile)
+)
+
+// loadProfile returns the named profile, reading its credentials on first
+// use. The variable holds either a service account key file path or the key
+// JSON itself.
+func loadProfile(ctx context.Context, name string) (*credentialProfile, error) {
+	variable := profileVariable(name)
+	raw := strings.TrimSpace(setting(ctx, variable))
+	if raw == "" {
+		return nil, fmt.Errorf("credential profile %q is not configured (%s is unset)", name, variable)
+	}
+
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	key := name + "\x00" + raw
+	if profile, ok := profiles[key]; ok {
+		return profile, nil
+	}
+	data := []byte(raw)
+	if !strings.HasPrefix(raw, "{") {
+		var err error
+		if data, err = os.ReadFile(raw); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", variable, err)
+		}
+	}
+	creds, err := google.CredentialsFromJSON(context.Background(), data, datastoreScope)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credentials in %s: %v", variable, err)
+	}
+
+	profile := &credentialProfile{source: creds.TokenSource, tokens: &tokenCache{}, projectID: creds.ProjectID}
+	profiles[key] = profile
+	return profile, nil
+}
+
+// ProfileDatabase returns the project and database that Firestore calls made
+// with ctx address. With a credential profile selected they are its
+// PROJECT_ID_<NAME> and DATABASE_ID_<NAME>, defaulting to the project of its
+// service account key and to databaseID. A profile whose credentials cannot
+// be read keeps projectID; its calls then fail on the token.
+func ProfileDatabase(ctx context.Context, projectID, databaseID string) (string, string) {
+	name := profileFrom(ctx)
+	if name == "" {
+		return projectID, databaseID
+	}
+	suffix := profileSuffix(name)
+	if database := setting(ctx, "DATABASE_ID_"+suffix); database != "" {
+		databaseID = database
+	}
+	if project := setting(ctx, "PROJECT_ID_"+suffix); project != "" {
+		return project, databaseID
+	}
+	if profile, err := loadProfile(ctx, name); err == nil && profile.projectID != "" {
+		projectID = profile.projectID
+	}
+	return projectID, databaseID
+}
+
+// profileAccessToken returns a token for the named profile from its own cache.
+func profileAccessToken(ctx context.Context, name string) (string, error) {
+	profile, err := loadProfile(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if token, ok := profile.tokens.get(); ok {
+		return token, nil
+	}
+	return fetchToken(ctx, profile.source, profile.tokens)
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// serviceAccountKey is a service account key for project key-project; its
+// private key is never used, as the tests don't fetch tokens.
+const serviceAccountKey = `{"type": "service_account", "project_id": "key-project", "client_email": "reader@key-project.iam.gserviceaccount.com", "private_key": "unused"}`
+
+func TestProfileDatabase(t *testing.T) {
+	tests := []struct {
+		name              string
+		profile           string
+		env               map[string]string
+		project, database string
+	}{
+		{"no profile", "", map[string]string{"CREDS_REPORTING": serviceAccountKey}, "p", "d"},
+		{"key project", "reporting", map[string]string{"CREDS_REPORTING": serviceAccountKey}, "key-project", "d"},
+		{"configured project", "reporting", map[string]string{
+			"CREDS_REPORTING": serviceAccountKey, "PROJECT_ID_REPORTING": "other", "DATABASE_ID_REPORTING": "archive",
+		}, "other", "archive"},
+		{"mangled name", "ops-eu", map[string]string{"CREDS_OPS_EU": serviceAccountKey, "DATABASE_ID_OPS_EU": "eu"}, "key-project", "eu"},
+		{"unreadable credentials", "reporting", map[string]string{"CREDS_REPORTING": "{not json"}, "p", "d"},
+	}
+	for _, tt := range tests {
+		ctx := withSettings(tt.env)
+		if tt.profile != "" {
+			ctx = WithProfile(ctx, tt.profile)
+		}
+		project, database := ProfileDatabase(ctx, "p", "d")
+		if project != tt.project || database != tt.database {
+			t.Errorf("%s: ProfileDatabase = %s, %s, want %s, %s", tt.name, project, database, tt.project, tt.database)
+		}
+	}
+}
+
+func TestProfileSelectsDatabase(t *testing.T) {
+	var path string
+	fakeFirestore(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(`{}`))
+	})
+
+	ctx := withSettings(map[string]string{"CREDS_REPORTING": serviceAccountKey, "DATABASE_ID_REPORTING": "archive"})
+	tests := []struct {
+		ctx  context.Context
+		want string
+	}{
+		{ctx, "/v1/projects/p/databases/d/documents:listCollectionIds"},
+		{WithProfile(ctx, "reporting"), "/v1/projects/key-project/databases/archive/documents:listCollectionIds"},
+	}
+	for _, tt := range tests {
+		if err := Ping(tt.ctx, "p", "d"); err != nil {
+			t.Fatalf("Ping: %v", err)
+		}
+		if path != tt.want {
+			t.Errorf("profile %q: path = %s, want %s", profileFrom(tt.ctx), path, tt.want)
+		}
+	}
+}
+
+func TestProfileTokenCaches(t *testing.T) {
+	ctx := withSettings(map[string]string{"CREDS_REPORTING": serviceAccountKey, "CREDS_OPS": serviceAccountKey})
+	reporting, err := loadProfile(ctx, "reporting")
+	if err != nil {
+		t.Fatalf("loadProfile(reporting): %v", err)
+	}
+	ops, err := loadProfile(ctx, "ops")
+	if err != nil {
+		t.Fatalf("loadProfile(ops): %v", err)
+	}
+	if again, _ := loadProfile(ctx, "reporting"); again != reporting {
+		t.Error("loadProfile(reporting) loaded the profile twice")
+	}
+
+	reporting.tokens.set(&oauth2.Token{AccessToken: "reporting-token", Expiry: time.Now().Add(time.Hour)})
+	if token, err := profileAccessToken(ctx, "reporting"); err != nil || token != "reporting-token" {
+		t.Errorf("profileAccessToken(reporting) = %q, %v, want the cached token", token, err)
+	}
+	if token, ok := ops.tokens.get(); ok {
+		t.Errorf("ops token cache holds %q from another profile", token)
+	}
+	if token, ok := defaultTokens.get(); ok && token == "reporting-token" {
+		t.Error("the default token cache holds the profile's token")
+	}
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// fieldOperators are the binary operators accepted in a fieldFilter.
+var fieldOperators = map[string]bool{
+	"EQUAL":                 true,
+	"NOT_EQUAL":             true,
+	"LESS_THAN":             true,
+	"LESS_THAN_OR_EQUAL":    true,
+	"GREATER_THAN":          true,
+	"GREATER_THAN_OR_EQUAL": true,
+	"ARRAY_CONTAINS":        true,
+	"ARRAY_CONTAINS_ANY":    true,
+	"IN":                    true,
+	"NOT_IN":                true,
+}
+
+// unaryOperators are the operators serialized as a unaryFilter.
+var unaryOperators = map[string]bool{
+	"IS_NULL":     true,
+	"IS_NOT_NULL": true,
+	"IS_NAN":      true,
+	"IS_NOT_NAN":  true,
+}
+
+// Filter is a single condition on a document field.
+type Filter struct {
+	Field string      `json:"field"`
+	Op    string      `json:"op"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Order sorts query results by a field.
+type Order struct {
+	Field     string `json:"field"`
+	Direction string `json:"direction,omitempty"`
+}
+
+// QuerySpec describes a structured query against a collection.
+type QuerySpec struct {
+	// Parent is the document path, relative to the documents root, whose
+	// subcollections are queried; empty queries the root collections.
+	Parent         string   `json:"-"`
+	Collection     string   `json:"collection"`
+	AllDescendants bool     `json:"allDescendants"`
+	Select         []string `json:"select"`
+	Where          []Filter `json:"where"`
+	OrderBy        []Order  `json:"orderBy"`
+	Limit          int      `json:"limit"`
+	LimitToLast    int      `json:"limitToLast"`
+
+	// skipNulls starts the results after the null values of the first
+	// orderBy field, which sort before every other value in ascending order
+	skipNulls bool
+	// afterName starts the results after the document with this full name,
+	// in the default order by name, to read a query page by page
+	afterName string
+}
+
+// parentURL returns the URL of the document the query runs under.
+func (q QuerySpec) parentURL(ctx context.Context, projectID, databaseID string) string {
+	if parent := strings.Trim(q.Parent, "/"); parent != "" {
+		return documentsURL(ctx, projectID, databaseID) + "/" + parent
+	}
+	return documentsURL(ctx, projectID, databaseID)
+}
+
+// StructuredQuery converts the spec into a Firestore structuredQuery object.
+func (q QuerySpec) StructuredQuery(ctx context.Context) (map[string]interface{}, error) {
+	if q.Collection == "" {
+		return nil, fmt.Errorf("collection is required")
+	}
+
+	query := map[string]interface{}{
+		"from": []map[string]interface{}{
+			{"collectionId": q.Collection, "allDescendants": q.AllDescendants},
+		},
+	}
+
+	if len(q.Select) > 0 {
+		var fields []map[string]interface{}
+		for _, path := range q.Select {
+			fieldPath, err := EscapeFieldPath(path)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, map[string]interface{}{"fieldPath": fieldPath})
+		}
+		query["select"] = map[string]interface{}{"fields": fields}
+	}
+
+	var filters []map[string]interface{}
+	for _, f := range q.Where {
+		if err := CheckFilterable(ctx, q.Collection, f.Field); err != nil {
+			return nil, err
+		}
+		if err := CheckOperator(ctx, f.Op); err != nil {
+			return nil, err
+		}
+		filter, err := f.serialize()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) == 1 {
+		query["where"] = filters[0]
+	} else if len(filters) > 1 {
+		query["where"] = map[string]interface{}{
+			"compositeFilter": map[string]interface{}{"op": "AND", "filters": filters},
+		}
+	}
+
+	// runQuery has no limitToLast: query the reversed order for the first N
+	// and let RunStructuredQuery restore the requested order
+	if q.LimitToLast > 0 && len(q.OrderBy) == 0 {
+		return nil, fmt.Errorf("limitToLast requires an orderBy")
+	}
+	if q.LimitToLast > 0 && q.Limit > 0 {
+		return nil, fmt.Errorf("limitToLast cannot be combined with limit")
+	}
+
+	if len(q.OrderBy) > 0 {
+		var orders []map[string]interface{}
+		for _, o := range q.OrderBy {
+			if err := CheckSortable(ctx, q.Collection, o.Field); err != nil {
+				return nil, err
+			}
+			direction := o.Direction
+			if direction == "" {
+				direction = "ASCENDING"
+			}
+			if direction != "ASCENDING" && direction != "DESCENDING" {
+				return nil, fmt.Errorf("invalid order direction %q", o.Direction)
+			}
+			if q.LimitToLast > 0 {
+				direction = reverseDirection(direction)
+			}
+			fieldPath, err := EscapeFieldPath(o.Field)
+			if err != nil {
+				return nil, err
+			}
+			orders = append(orders, map[string]interface{}{
+				"field":     map[string]interface{}{"fieldPath": fieldPath},
+				"direction": direction,
+			})
+		}
+		query["orderBy"] = orders
+	}
+
+	if q.skipNulls {
+		if len(q.OrderBy) == 0 || q.LimitToLast > 0 {
+			return nil, fmt.Errorf("skipping nulls requires an orderBy without limitToLast")
+		}
+		query["startAt"] = map[string]interface{}{
+			"values": []interface{}{map[string]interface{}{"nullValue": nil}},
+			"before": false,
+		}
+	}
+
+	if q.afterName != "" {
+		if len(q.OrderBy) > 0 || q.LimitToLast > 0 {
+			return nil, fmt.Errorf("paging by name cannot be combined with orderBy or limitToLast")
+		}
+		query["startAt"] = map[string]interface{}{
+			"values": []interface{}{map[string]interface{}{"referenceValue": q.afterName}},
+			"before": false,
+		}
+	}
+
+	if q.LimitToLast > 0 {
+		query["limit"] = q.LimitToLast
+	} else if q.Limit > 0 {
+		query["limit"] = q.Limit
+	}
+
+	return query, nil
+}
+
+// reverseDirection flips an ASCENDING or DESCENDING order direction.
+func reverseDirection(direction string) string {
+	if direction == "ASCENDING" {
+		return "DESCENDING"
+	}
+	return "ASCENDING"
+}
+
+// serialize converts the filter into a Firestore fieldFilter or unaryFilter.
+func (f Filter) serialize() (map[string]interface{}, error) {
+	if f.Field == "" {
+		return nil, fmt.Errorf("filter field is required")
+	}
+
+	fieldPath, err := EscapeFieldPath(f.Field)
+	if err != nil {
+		return nil, err
+	}
+	field := map[string]interface{}{"fieldPath": fieldPath}
+	if unaryOperators[f.Op] {
+		return map[string]interface{}{
+			"unaryFilter": map[string]interface{}{"op": f.Op, "field": field},
+		}, nil
+	}
+	if !fieldOperators[f.Op] {
+		return nil, fmt.Errorf("unsupported filter operator %q", f.Op)
+	}
+
+	return map[string]interface{}{
+		"fieldFilter": map[string]interface{}{
+			"field": field,
+			"op":    f.Op,
+			"value": EncodeValue(f.Value),
+		},
+	}, nil
+}
+
+// EncodeValue converts a plain Go value into a Firestore typed value.
+func EncodeValue(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case nil:
+		return map[string]interface{}{"nullValue": nil}
+	case bool:
+		return map[string]interface{}{"booleanValue": v}
+	case int:
+		return map[string]interface{}{"integerValue": fmt.Sprint(v)}
+	case int64:
+		return map[string]interface{}{"integerValue": fmt.Sprint(v)}
+	case json.Number:
+		// Request bodies are decoded with UseNumber so integers beyond 2^53 keep
+		// every digit
+		if n, err := v.Int64(); err == nil {
+			return map[string]interface{}{"integerValue": fmt.Sprint(n)}
+		}
+		f, _ := v.Float64()
+		return EncodeValue(f)
+	case float64:
+		// JSON numbers arrive as float64; keep whole numbers as integers
+		if v == float64(int64(v)) {
+			return map[string]interface{}{"integerValue": fmt.Sprint(int64(v))}
+		}
+		return map[string]interface{}{"doubleValue": v}
+	case time.Time:
+		return map[string]interface{}{"timestampValue": v.Format(time.RFC3339Nano)}
+	case []interface{}:
+		values := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			values = append(values, EncodeValue(item))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case map[string]interface{}:
+		fields := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			fields[key] = EncodeValue(item)
+		}
+		return map[string]interface{}{"mapValue": map[string]interface{}{"fields": fields}}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprint(value)}
+}
+
+// RunStructuredQuery executes a QuerySpec with runQuery and returns the matched documents.
+func RunStructuredQuery(ctx context.Context, projectID, databaseID string, spec QuerySpec) ([]FirestoreDocument, error) {
+	structuredQuery, err := spec.StructuredQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryURL := spec.parentURL(ctx, projectID, databaseID) + ":runQuery"
+
+	var documents []FirestoreDocument
+	payload := map[string]interface{}{"structuredQuery": structuredQuery}
+	addReadPayload(ctx, payload)
+	documents, err = streamRunQuery(ctx, queryURL, payload)
+	if err != nil {
+		return nil, err
+	}
+	if spec.LimitToLast > 0 {
+		reverseDocuments(documents)
+	}
+
+	return documents, nil
+}
+
+// streamRunQuery posts a runQuery payload and decodes the streamed documents,
+// with their short IDs set, recording the stream's readTime on the request.
+// On an explaining request the query is analyzed and its explainMetrics are
+// recorded too.
+func streamRunQuery(ctx context.Context, queryURL string, payload map[string]interface{}) ([]FirestoreDocument, error) {
+	addExplainPayload(ctx, payload)
+
+	var result runQueryResult
+	err := firestoreStream(ctx, "POST", queryURL, payload, func(dec *json.Decoder) error {
+		var err error
+		result, err = decodeRunQuery(dec)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	recordReadTime(ctx, result.ReadTime)
+	recordExplainMetrics(ctx, result.ExplainMetrics)
+	setDocumentIDs(result.Documents)
+	return result.Documents, nil
+}
+
+// runQueryResult is a decoded runQuery response.
+type runQueryResult struct {
+	Documents      []FirestoreDocument
+	ReadTime       string
+	ExplainMetrics json.RawMessage
+}
+
+// decodeRunQuery reads a runQuery response array one element at a time. Only
+// entries with a document are kept; the others (partial progress entries
+// reporting skippedResults, and the terminal entry carrying only readTime,
+// done or explainMetrics) are skipped. The last readTime reported, which is
+// the one of the terminal entry, is returned with the documents, as are the
+// explainMetrics of an explained query.
+func decodeRunQuery(dec *json.Decoder) (runQueryResult, error) {
+	var result runQueryResult
+	if err := expectDelim(dec, '['); err != nil {
+		return result, err
+	}
+
+	for dec.More() {
+		var entry struct {
+			Document       *FirestoreDocument `json:"document"`
+			ReadTime       string             `json:"readTime"`
+			ExplainMetrics json.RawMessage    `json:"explainMetrics"`
+		}
+		if err := dec.Decode(&entry); err != nil {
+			return runQueryResult{}, err
+		}
+		if entry.ReadTime != "" {
+			result.ReadTime = entry.ReadTime
+		}
+		if len(entry.ExplainMetrics) > 0 {
+			result.ExplainMetrics = entry.ExplainMetrics
+		}
+		if entry.Document != nil {
+			result.Documents = append(result.Documents, *entry.Document)
+		}
+	}
+
+	if err := expectDelim(dec, ']'); err != nil {
+		return runQueryResult{}, err
+	}
+	return result, nil
+}
+
+// expectDelim reads the next JSON token and checks that it is delim.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v in runQuery response, got %v", delim, token)
+	}
+	return nil
+}
+
+// reverseDocuments reverses a document slice in place.
+func reverseDocuments(docs []FirestoreDocument) {
+	for i, j := 0, len(docs)-1; i < j; i, j = i+1, j-1 {
+		docs[i], docs[j] = docs[j], docs[i]
+	}
+}
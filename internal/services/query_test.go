@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestQuerySpecParentURL(t *testing.T) {
+	tests := []struct {
+		parent, want string
+	}{
+		{"", "https://firestore.googleapis.com/v1/projects/p/databases/d/documents"},
+		{"dead-letters/NANALL", "https://firestore.googleapis.com/v1/projects/p/databases/d/documents/dead-letters/NANALL"},
+		{"/dead-letters/NANALL/", "https://firestore.googleapis.com/v1/projects/p/databases/d/documents/dead-letters/NANALL"},
+	}
+	for _, tt := range tests {
+		spec := QuerySpec{Parent: tt.parent, Collection: "2024-12-16"}
+		if got := spec.parentURL(context.Background(), "p", "d"); got != tt.want {
+			t.Errorf("parentURL with Parent %q = %q, want %q", tt.parent, got, tt.want)
+		}
+	}
+}
+
+func TestStructuredQuerySkipsNulls(t *testing.T) {
+	spec := QuerySpec{Collection: "orders", OrderBy: []Order{{Field: "createdAt"}}, Limit: 1, skipNulls: true}
+	query, err := spec.StructuredQuery(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"values": []interface{}{map[string]interface{}{"nullValue": nil}},
+		"before": false,
+	}
+	if !reflect.DeepEqual(query["startAt"], want) {
+		t.Errorf("startAt = %v, want %v", query["startAt"], want)
+	}
+
+	spec.OrderBy = nil
+	if _, err := spec.StructuredQuery(context.Background()); err == nil {
+		t.Error("StructuredQuery skipped nulls without an orderBy")
+	}
+}
+
+func TestStructuredQueryAllowedOperators(t *testing.T) {
+	tests := []struct {
+		allowed string
+		op      string
+		wantErr bool
+	}{
+		{"", "GREATER_THAN", false},
+		{"EQUAL,IN", "EQUAL", false},
+		{"equal, in", "IN", false},
+		{"EQUAL,IN", "GREATER_THAN", true},
+		{"EQUAL", "IS_NULL", true},
+	}
+	for _, tt := range tests {
+		ctx := withSettings(map[string]string{"ALLOWED_FILTER_OPS": tt.allowed})
+		spec := QuerySpec{Collection: "orders", Where: []Filter{{Field: "total", Op: tt.op, Value: 1}}}
+		if tt.op == "IS_NULL" {
+			spec.Where[0].Value = nil
+		}
+		_, err := spec.StructuredQuery(ctx)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ALLOWED_FILTER_OPS=%q, op %s: error = %v, want error %v", tt.allowed, tt.op, err, tt.wantErr)
+		}
+	}
+}
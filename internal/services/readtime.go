@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// resultReadTimeKey is the context key holding a *ResultReadTime.
+type resultReadTimeKey struct{}
+
+// ResultReadTime records the readTime Firestore reported for a request's
+// query results. When a request runs several queries the earliest is kept,
+// so it bounds how old any of the results may be.
+type ResultReadTime struct {
+	mu       sync.Mutex
+	readTime string
+	earliest time.Time
+}
+
+// WithResultReadTime returns a context that records the readTime of query results.
+func WithResultReadTime(ctx context.Context) (context.Context, *ResultReadTime) {
+	recorder := &ResultReadTime{}
+	return context.WithValue(ctx, resultReadTimeKey{}, recorder), recorder
+}
+
+// Value returns the recorded readTime (RFC3339), or "" when none was reported.
+func (r *ResultReadTime) Value() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.readTime
+}
+
+// recordReadTime records a query's readTime on the context's request, if it
+// tracks one.
+func recordReadTime(ctx context.Context, readTime string) {
+	recorder, _ := ctx.Value(resultReadTimeKey{}).(*ResultReadTime)
+	if recorder == nil {
+		return
+	}
+	t, err := time.Parse(time.RFC3339Nano, readTime)
+	if err != nil {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if recorder.readTime == "" || t.Before(recorder.earliest) {
+		recorder.readTime = readTime
+		recorder.earliest = t
+	}
+}
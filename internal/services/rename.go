@@ -0,0 +1,88 @@
+package services
+
+import "sort"
+
+// RenameKeys renames map keys at any depth of a decoded document, including
+// maps inside arrays. Keys without an entry in renames are kept as they are.
+// When a map already holds a key under a rename's target name, and that key is
+// not renamed itself, its value is kept and the renamed key is dropped. Two
+// keys renamed to the same name are rejected when the field config is loaded.
+func RenameKeys(decoded map[string]interface{}, renames map[string]string) map[string]interface{} {
+	if len(renames) == 0 {
+		return decoded
+	}
+	return renameValue(decoded, renames).(map[string]interface{})
+}
+
+// renameValue applies renames to a single decoded value.
+func renameValue(value interface{}, renames map[string]string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		renamed := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if to, ok := renames[key]; ok {
+				if _, exists := v[to]; exists {
+					if _, moved := renames[to]; !moved {
+						continue
+					}
+				}
+				key = to
+			}
+			renamed[key] = renameValue(item, renames)
+		}
+		return renamed
+	case []interface{}:
+		renamed := make([]interface{}, len(v))
+		for i, item := range v {
+			renamed[i] = renameValue(item, renames)
+		}
+		return renamed
+	}
+	return value
+}
+
+// CanonicalizeKeys renames alternative field names to their canonical names at
+// any depth of a decoded document. When a map already holds the canonical key,
+// its value is kept and the alternatives are dropped; among several
+// alternatives the one sorting first wins.
+func CanonicalizeKeys(decoded map[string]interface{}, canonical map[string]string) map[string]interface{} {
+	if len(canonical) == 0 {
+		return decoded
+	}
+	return canonicalizeValue(decoded, canonical).(map[string]interface{})
+}
+
+// canonicalizeValue applies CanonicalizeKeys to a single decoded value.
+func canonicalizeValue(value interface{}, canonical map[string]string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		renamed := make(map[string]interface{}, len(v))
+		for _, key := range keys {
+			target := key
+			if to, ok := canonical[key]; ok {
+				if _, exists := v[to]; exists {
+					continue
+				}
+				if _, taken := renamed[to]; taken {
+					continue
+				}
+				target = to
+			}
+			renamed[target] = canonicalizeValue(v[key], canonical)
+		}
+		return renamed
+	case []interface{}:
+		renamed := make([]interface{}, len(v))
+		for i, item := range v {
+			renamed[i] = canonicalizeValue(item, canonical)
+		}
+		return renamed
+	}
+	return value
+}
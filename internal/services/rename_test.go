@@ -0,0 +1,42 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenameKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		decoded map[string]interface{}
+		renames map[string]string
+		want    map[string]interface{}
+	}{
+		{
+			name:    "nested and in arrays",
+			decoded: map[string]interface{}{"storeCode": "S1", "items": []interface{}{map[string]interface{}{"qty": 2}}},
+			renames: map[string]string{"storeCode": "store", "qty": "quantity"},
+			want:    map[string]interface{}{"store": "S1", "items": []interface{}{map[string]interface{}{"quantity": 2}}},
+		},
+		{
+			name:    "existing target is kept",
+			decoded: map[string]interface{}{"storeCode": "old", "store": "new"},
+			renames: map[string]string{"storeCode": "store"},
+			want:    map[string]interface{}{"store": "new"},
+		},
+		{
+			name:    "chained",
+			decoded: map[string]interface{}{"a": 1, "b": 2},
+			renames: map[string]string{"a": "b", "b": "c"},
+			want:    map[string]interface{}{"b": 1, "c": 2},
+		},
+	}
+	for _, tt := range tests {
+		// Map order varies between runs, so rename each case repeatedly
+		for i := 0; i < 20; i++ {
+			if got := RenameKeys(tt.decoded, tt.renames); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("%s: RenameKeys = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// defaultRetryBudget bounds retries when neither the caller nor RETRY_BUDGET
+// sets a deadline.
+const defaultRetryBudget = 5 * time.Second
+
+// retryBudget returns RETRY_BUDGET, how long retries of a failing call may go
+// on when the caller's context has no deadline.
+func retryBudget(ctx context.Context) time.Duration {
+	raw := setting(ctx, "RETRY_BUDGET")
+	if raw == "" {
+		return defaultRetryBudget
+	}
+	budget, err := time.ParseDuration(raw)
+	if err != nil || budget < 0 {
+		log.Printf("Warning: ignoring invalid RETRY_BUDGET %q", raw)
+		return defaultRetryBudget
+	}
+	return budget
+}
+
+// retry runs op until it succeeds, fails with an error transient rejects, or
+// the next attempt would start after the deadline: the context's deadline, or
+// RETRY_BUDGET from now when it has none. The delay before the first retry is
+// backoff and doubles after each one. The last error is returned, wrapped in
+// the context's error when retries stopped because of the context, so callers
+// can tell a timeout from a failure.
+func retry(ctx context.Context, backoff time.Duration, transient func(error) bool, op func() error) error {
+	deadline, hasDeadline := ctx.Deadline()
+	if !hasDeadline {
+		deadline = time.Now().Add(retryBudget(ctx))
+	}
+
+	delay := backoff
+	for {
+		err := op()
+		if err == nil || !transient(err) {
+			return err
+		}
+		if time.Now().Add(delay).After(deadline) {
+			if hasDeadline {
+				return fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+			}
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
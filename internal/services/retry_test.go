@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryReportsTheDeadline(t *testing.T) {
+	failure := errors.New("token endpoint unavailable")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	err := retry(ctx, 20*time.Millisecond, func(error) bool { return true }, func() error {
+		attempts++
+		return failure
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, failure) {
+		t.Fatalf("retry = %v, want the last error wrapped in context.DeadlineExceeded", err)
+	}
+	if attempts < 2 {
+		t.Errorf("retry made %d attempts, want at least 2", attempts)
+	}
+}
+
+func TestRetryStopsOnPermanentErrors(t *testing.T) {
+	failure := errors.New("invalid_grant")
+	attempts := 0
+	err := retry(context.Background(), time.Millisecond, func(error) bool { return false }, func() error {
+		attempts++
+		return failure
+	})
+	if err != failure || attempts != 1 {
+		t.Fatalf("retry = %v after %d attempts, want %v after 1", err, attempts, failure)
+	}
+}
@@ -0,0 +1,14 @@
+package services
+
+import (
+	"context"
+
+	"crossfire-grafana/internal/config"
+)
+
+// setting returns the named variable from the configuration snapshot ctx is
+// served with (see config.WithRuntime), so a reload never changes a setting
+// partway through a request.
+func setting(ctx context.Context, name string) string {
+	return config.FromContext(ctx).Getenv(name)
+}
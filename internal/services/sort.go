@@ -0,0 +1,119 @@
+package services
+
+import (
+	"sort"
+	"time"
+)
+
+// OrderDocuments sorts documents in place by the decoded value at a dot-notation
+// field path. Numbers compare numerically, timestamps chronologically and strings
+// lexicographically; documents missing the field (or holding null) sort last in
+// either direction. dir is "asc" or "desc".
+func OrderDocuments(docs []FirestoreDocument, field, dir string) {
+	keys := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		decoded, err := DecodeFields(doc.Fields)
+		if err != nil {
+			continue
+		}
+		keys[i], _ = LookupPath(decoded, field)
+	}
+
+	// Sort an index so the precomputed keys move together with their documents
+	index := make([]int, len(docs))
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(a, b int) bool {
+		ka, kb := keys[index[a]], keys[index[b]]
+		if ka == nil || kb == nil {
+			return ka != nil && kb == nil
+		}
+		cmp := compareValues(ka, kb)
+		if dir == "desc" {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	sorted := make([]FirestoreDocument, len(docs))
+	for i, j := range index {
+		sorted[i] = docs[j]
+	}
+	copy(docs, sorted)
+}
+
+// compareValues orders two non-nil decoded values, grouping mismatched types
+// by kind so mixed-type fields still sort deterministically.
+func compareValues(a, b interface{}) int {
+	ra, rb := valueRank(a), valueRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+
+	switch va := a.(type) {
+	case time.Time:
+		return va.Compare(b.(time.Time))
+	case string:
+		vb := b.(string)
+		if va < vb {
+			return -1
+		} else if va > vb {
+			return 1
+		}
+		return 0
+	case int64:
+		if vb, ok := b.(int64); ok {
+			if va < vb {
+				return -1
+			} else if va > vb {
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		vb := b.(bool)
+		if va == vb {
+			return 0
+		} else if !va {
+			return -1
+		}
+		return 1
+	}
+
+	if na, ok := toFloat(a); ok {
+		nb, _ := toFloat(b)
+		if na < nb {
+			return -1
+		} else if na > nb {
+			return 1
+		}
+	}
+	return 0
+}
+
+// valueRank groups decoded values by kind: numbers, timestamps, strings, bools, other.
+func valueRank(v interface{}) int {
+	switch v.(type) {
+	case int64, float64:
+		return 0
+	case time.Time:
+		return 1
+	case string:
+		return 2
+	case bool:
+		return 3
+	}
+	return 4
+}
+
+// toFloat converts a decoded numeric value to float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+// orderDoc builds a document whose "value" field holds value, or no field
+// when value is nil.
+func orderDoc(id string, value map[string]interface{}) FirestoreDocument {
+	fields := map[string]interface{}{}
+	if value != nil {
+		fields["value"] = value
+	}
+	return FirestoreDocument{ID: id, Fields: fields}
+}
+
+func TestOrderDocuments(t *testing.T) {
+	docs := func() []FirestoreDocument {
+		return []FirestoreDocument{
+			orderDoc("ten", map[string]interface{}{"integerValue": "10"}),
+			orderDoc("missing", nil),
+			orderDoc("half", map[string]interface{}{"doubleValue": 0.5}),
+			orderDoc("null", map[string]interface{}{"nullValue": nil}),
+			orderDoc("text", map[string]interface{}{"stringValue": "a"}),
+			orderDoc("two", map[string]interface{}{"integerValue": "2"}),
+			orderDoc("time", map[string]interface{}{"timestampValue": "2024-12-16T00:00:00Z"}),
+		}
+	}
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{"asc", []string{"half", "two", "ten", "time", "text", "missing", "null"}},
+		{"desc", []string{"text", "time", "ten", "two", "half", "missing", "null"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			sorted := docs()
+			OrderDocuments(sorted, "value", tt.dir)
+			var got []string
+			for _, doc := range sorted {
+				got = append(got, doc.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OrderDocuments(%s) = %v, want %v", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderDocumentsByNestedPath(t *testing.T) {
+	state := func(id, value string) FirestoreDocument {
+		return FirestoreDocument{ID: id, Fields: map[string]interface{}{
+			"billTo": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+				"state": map[string]interface{}{"stringValue": value},
+			}}},
+		}}
+	}
+	docs := []FirestoreDocument{state("vic", "VIC"), state("nsw", "NSW"), state("qld", "QLD")}
+	OrderDocuments(docs, "billTo.state", "asc")
+	if docs[0].ID != "nsw" || docs[1].ID != "qld" || docs[2].ID != "vic" {
+		t.Errorf("OrderDocuments by billTo.state = %s, %s, %s; want nsw, qld, vic", docs[0].ID, docs[1].ID, docs[2].ID)
+	}
+}
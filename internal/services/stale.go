@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// staleKey is the context key holding a *StaleFlag.
+type staleKey struct{}
+
+// StaleFlag records that a request was served cached data past its TTL.
+type StaleFlag struct {
+	stale atomic.Bool
+}
+
+// WithStaleFlag returns a context that records whether stale data was served.
+func WithStaleFlag(ctx context.Context) (context.Context, *StaleFlag) {
+	flag := &StaleFlag{}
+	return context.WithValue(ctx, staleKey{}, flag), flag
+}
+
+// Stale reports whether any read in the request was served stale.
+func (f *StaleFlag) Stale() bool {
+	return f.stale.Load()
+}
+
+// markStale flags the context's request as served stale, if it tracks it.
+func markStale(ctx context.Context) {
+	if flag, _ := ctx.Value(staleKey{}).(*StaleFlag); flag != nil {
+		flag.stale.Store(true)
+	}
+}
+
+// staleMaxAge returns STALE_MAX_AGE, how old a cached read may be when it is
+// served because Firestore failed. Zero (the default) disables stale serving.
+func staleMaxAge(ctx context.Context) time.Duration {
+	maxAge, err := time.ParseDuration(setting(ctx, "STALE_MAX_AGE"))
+	if err != nil || maxAge < 0 {
+		return 0
+	}
+	return maxAge
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// defaultReadTimeout applies when neither a per-collection nor FIRESTORE_TIMEOUT is set.
+const defaultReadTimeout = 30 * time.Second
+
+// ReadTimeout returns the Firestore read timeout for a collection or route:
+// TIMEOUT_<NAME> (upper-cased with non-alphanumerics removed, e.g.
+// TIMEOUT_DEADLETTERS for dead-letters), then FIRESTORE_TIMEOUT, then 30s.
+func ReadTimeout(ctx context.Context, name string) time.Duration {
+	for _, key := range []string{"TIMEOUT_" + timeoutKey(name), "FIRESTORE_TIMEOUT"} {
+		if timeout, err := time.ParseDuration(setting(ctx, key)); err == nil && timeout > 0 {
+			return timeout
+		}
+	}
+	return defaultReadTimeout
+}
+
+// WithReadTimeout bounds ctx by the read timeout configured for name.
+func WithReadTimeout(ctx context.Context, name string) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, ReadTimeout(ctx, name))
+}
+
+// timeoutKey converts a collection or route name into an env var suffix.
+func timeoutKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
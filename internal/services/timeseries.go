@@ -0,0 +1,63 @@
+package services
+
+import "time"
+
+// maxSeriesBuckets bounds the number of points generated for a single series.
+const maxSeriesBuckets = 10000
+
+// DocumentTime extracts the time stored at a dot-notation field of a document.
+// The field may hold a native timestampValue or an RFC3339 stringValue, since
+// older documents store times as strings; any other value yields false.
+func DocumentTime(doc FirestoreDocument, field string) (time.Time, bool) {
+	decoded, err := DecodeFields(doc.Fields)
+	if err != nil {
+		return time.Time{}, false
+	}
+	value, ok := LookupPath(decoded, field)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// CountSeries counts documents per interval bucket between from and to, using
+// the time stored at field. It returns SimpleJSON datapoints ([value, unix ms])
+// in chronological order, with empty buckets reported as zero.
+func CountSeries(docs []FirestoreDocument, field string, from, to time.Time, interval time.Duration) [][]float64 {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	// Widen the interval rather than emitting an unbounded number of points
+	if span := to.Sub(from); span/interval > maxSeriesBuckets {
+		interval = span / maxSeriesBuckets
+	}
+
+	start := from.Truncate(interval)
+	counts := make(map[int64]float64)
+	for _, doc := range docs {
+		t, ok := DocumentTime(doc, field)
+		if !ok || t.Before(from) || t.After(to) {
+			continue
+		}
+		bucket := t.Truncate(interval)
+		counts[bucket.UnixMilli()]++
+	}
+
+	datapoints := [][]float64{}
+	for bucket := start; !bucket.After(to); bucket = bucket.Add(interval) {
+		ms := bucket.UnixMilli()
+		datapoints = append(datapoints, []float64{counts[ms], float64(ms)})
+	}
+	return datapoints
+}
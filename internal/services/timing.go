@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// fetchTimerKey is the context key holding a *FetchTimer.
+type fetchTimerKey struct{}
+
+// FetchTimer measures how long a request spent waiting on Firestore: the wall
+// time during which at least one Firestore call was in flight, so calls made
+// in parallel are not counted twice.
+type FetchTimer struct {
+	mu       sync.Mutex
+	inFlight int
+	since    time.Time
+	elapsed  time.Duration
+}
+
+// WithFetchTimer returns a context whose Firestore calls are timed by a new FetchTimer.
+func WithFetchTimer(ctx context.Context) (context.Context, *FetchTimer) {
+	timer := &FetchTimer{}
+	return context.WithValue(ctx, fetchTimerKey{}, timer), timer
+}
+
+// Elapsed returns the time spent in Firestore calls so far, including calls
+// still in flight.
+func (t *FetchTimer) Elapsed() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.inFlight > 0 {
+		return t.elapsed + time.Since(t.since)
+	}
+	return t.elapsed
+}
+
+// startFetch marks the start of a Firestore call on the context's timer, if
+// it has one, and returns the function marking its end.
+func startFetch(ctx context.Context) func() {
+	timer, _ := ctx.Value(fetchTimerKey{}).(*FetchTimer)
+	if timer == nil {
+		return func() {}
+	}
+	timer.mu.Lock()
+	if timer.inFlight == 0 {
+		timer.since = time.Now()
+	}
+	timer.inFlight++
+	timer.mu.Unlock()
+
+	return func() {
+		timer.mu.Lock()
+		defer timer.mu.Unlock()
+		timer.inFlight--
+		if timer.inFlight == 0 {
+			timer.elapsed += time.Since(timer.since)
+		}
+	}
+}
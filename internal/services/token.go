@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// tokenBackoff is the delay before the first retry; it doubles per attempt.
+var tokenBackoff = 100 * time.Millisecond
+
+// tokenCache holds the last token obtained for one set of credentials.
+type tokenCache struct {
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+// defaultTokens caches the token of the default credentials.
+var defaultTokens = &tokenCache{}
+
+// get returns the cached token while it is still valid.
+func (t *tokenCache) get() (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token.Valid() {
+		return t.token.AccessToken, true
+	}
+	return "", false
+}
+
+// set replaces the cached token.
+func (t *tokenCache) set(token *oauth2.Token) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.token = token
+}
+
+// fetchToken gets a token from source, retrying transient failures with
+// exponential backoff for as long as ctx allows, and stores it in cache.
+// Permanent credential errors are returned immediately.
+func fetchToken(ctx context.Context, source oauth2.TokenSource, cache *tokenCache) (string, error) {
+	var token *oauth2.Token
+	err := retry(ctx, tokenBackoff, transientTokenError, func() error {
+		var err error
+		token, err = source.Token()
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	cache.set(token)
+	return token.AccessToken, nil
+}
+
+// transientTokenError reports whether a token failure may succeed on retry.
+// Rejections from the token endpoint (4xx, e.g. a revoked key or disabled
+// account) are permanent; network errors and 5xx responses are transient.
+func transientTokenError(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.Response != nil {
+		status := retrieveErr.Response.StatusCode
+		return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+	}
+	return true
+}
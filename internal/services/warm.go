@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+)
+
+// CacheWarmer pre-fetches collections at startup so the first requests are
+// served from the cache.
+type CacheWarmer struct {
+	backend     FirestoreBackend
+	collections []string
+	ready       atomic.Bool
+}
+
+// NewCacheWarmer creates a warmer for collections (WARM_COLLECTIONS). With no
+// collections it is ready immediately.
+func NewCacheWarmer(backend FirestoreBackend, collections []string) *CacheWarmer {
+	w := &CacheWarmer{backend: backend, collections: collections}
+	if len(w.collections) == 0 {
+		w.ready.Store(true)
+	}
+	return w
+}
+
+// Run fetches each collection once through the backend, then marks the warmer
+// ready. Collections that fail to load are logged and left cold.
+func (w *CacheWarmer) Run(ctx context.Context) {
+	for _, collection := range w.collections {
+		readCtx, cancel := WithReadTimeout(ctx, collection)
+		documents, _, err := w.backend.FetchCollection(readCtx, collection)
+		cancel()
+		if err != nil {
+			log.Printf("Warning: failed to warm cache for %s: %v", collection, err)
+			continue
+		}
+		log.Printf("Warmed cache for %s (%d documents)", collection, len(documents))
+	}
+	w.ready.Store(true)
+}
+
+// Ready reports whether the initial warm has completed.
+func (w *CacheWarmer) Ready() bool {
+	return w.ready.Load()
+}
@@ -0,0 +1,5 @@
+package version
+
+// Version is the build version, overridden at build time with
+// -ldflags "-X crossfire-grafana/internal/version.Version=<version>".
+var Version = "dev"
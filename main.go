@@ -1,34 +1,84 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"io/fs"
 	"log"
-	"os"
+	"time"
 
-	"github.com/joho/godotenv"
+	"crossfire-grafana/internal/cache"
+	"crossfire-grafana/internal/config"
 	"crossfire-grafana/internal/routes" // Import the routes package
+	"crossfire-grafana/internal/services"
+	"github.com/joho/godotenv"
 )
 
 func main() {
-	// Load environment variables from .env
-	err := godotenv.Load()
-	if err != nil {
+	// Load environment variables from .env when present; deployments such as
+	// Cloud Run set them on the process instead. Settings are read from the
+	// config snapshot; the process environment is only loaded for libraries
+	// such as the Google credentials lookup.
+	if err := godotenv.Load(); errors.Is(err, fs.ErrNotExist) {
+		log.Println("No .env file found, using the process environment")
+	} else if err != nil {
 		log.Fatalf("Error loading .env file: %v", err)
 	}
 
-	// Get environment variables
-	projectID := os.Getenv("PROJECT_ID")
-	databaseID := os.Getenv("DATABASE_ID")
+	// Read the configuration once; the reloadable part becomes the active Runtime
+	env, err := config.LoadEnv(".env")
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	cfg, err := config.LoadConfig(env)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	config.Store(cfg.Runtime)
+	services.Configure(cfg)
 
-	if projectID == "" || databaseID == "" {
-		log.Fatalf("Environment variables PROJECT_ID and DATABASE_ID must be set.")
+	// Fail fast in development when no credentials are available
+	if err := services.CheckCredentials(context.Background()); err != nil {
+		if cfg.AppEnv == "development" {
+			log.Fatalf("Startup check failed: %v", err)
+		}
+		log.Printf("Warning: %v", err)
 	}
 
-	// Set up the HTTP server
-	router := routes.SetupRouter(projectID, databaseID)
+	// Select the Firestore backend
+	backend, err := services.NewBackend(cfg.Backend, cfg.ProjectID, cfg.DatabaseID)
+	if err != nil {
+		log.Fatalf("Failed to set up Firestore backend: %v", err)
+	}
+
+	// Cache collection reads, with per-collection TTLs from the field config file
+	backend = services.CachedBackend{
+		Backend: backend,
+		Cache:   cache.New(cfg.CacheMaxEntries),
+		TTL: func(ctx context.Context, collection string) time.Duration {
+			rt := config.FromContext(ctx)
+			return rt.Fields.CacheTTL(collection, rt.CacheTTL)
+		},
+	}
+
+	// Warm the cache in the background; /ready reports 503 until it finishes
+	warmer := services.NewCacheWarmer(backend, cfg.WarmCollections)
+	go warmer.Run(context.Background())
+
+	// Set up the HTTP server, including config-driven routes
+	configuredRoutes, err := config.LoadRoutes(cfg.RoutesConfigFile)
+	if err != nil {
+		log.Fatalf("Failed to load routes config: %v", err)
+	}
+	router, err := routes.SetupRouter(cfg, backend, warmer, configuredRoutes)
+	if err != nil {
+		log.Fatalf("Failed to set up routes: %v", err)
+	}
 
 	// Start the server
-	log.Println("Server is running on port 4000")
-	if err := router.Run(":4000"); err != nil {
+	config.LogStartup(cfg)
+	log.Println("Server is running on port " + cfg.Port)
+	if err := router.Run(":" + cfg.Port); err != nil {
 		log.Fatalf("Failed to run server: %v", err)
 	}
 }